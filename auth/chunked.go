@@ -0,0 +1,239 @@
+package auth
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Algorithme des signatures de chunk (aws-chunked)
+const chunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+
+// Taille maximale d'une ligne d'en-tête de chunk ("taille;chunk-signature=...\r\n")
+const maxChunkHeaderLength = 4096
+
+var (
+    ErrChunkMalformed       = errors.New("the aws-chunked body is malformed")
+    ErrIncompleteBody       = errors.New("the decoded body length does not match X-Amz-Decoded-Content-Length")
+    ErrMissingContentLength = errors.New("streaming uploads require a valid X-Amz-Decoded-Content-Length header")
+)
+
+// chunkVerifier laisse passer un corps aws-chunked tel quel en vérifiant au fil de la lecture
+// la signature chaînée de chaque chunk et la taille totale décodée.
+// Les données d'un chunk sont transmises avant que sa signature soit connue comme valide :
+// l'erreur arrive au plus tard à la place du CRLF qui suit, le lecteur doit donc abandonner l'écriture.
+type chunkVerifier struct {
+    body   io.ReadCloser
+    reader *bufio.Reader
+
+    signingKey        []byte
+    scope             Scope
+    timestamp         string
+    previousSignature string
+
+    decodedLength int64
+    total         int64
+
+    pending   []byte    // octets d'en-tête ou CRLF prêts à être rendus
+    inChunk   bool      // des données de chunk sont en cours de lecture
+    remaining int64     // octets de données restant dans le chunk courant
+    hash      hash.Hash // SHA-256 des données du chunk courant
+    signature string    // signature annoncée pour le chunk courant
+    done      bool
+    err       error
+}
+
+// NewChunkVerifier enveloppe un corps STREAMING-AWS4-HMAC-SHA256-PAYLOAD dont la requête a été vérifiée par VerifyRequest.
+// La signature de la requête sert de graine à la chaîne des signatures de chunk.
+func NewChunkVerifier(body io.ReadCloser, result *Result, decodedLength int64) io.ReadCloser {
+    return &chunkVerifier{
+        body:              body,
+        reader:            bufio.NewReader(body),
+        signingKey:        result.SigningKey,
+        scope:             result.Scope,
+        timestamp:         result.Time.UTC().Format(TimeFormat),
+        previousSignature: result.Signature,
+        decodedLength:     decodedLength,
+        hash:              sha256.New(),
+    }
+}
+
+func (cv *chunkVerifier) Read(p []byte) (int, error) {
+    for len(cv.pending) == 0 {
+        if cv.err != nil {
+            return 0, cv.err
+        }
+        switch {
+        case cv.inChunk && cv.remaining > 0:
+            return cv.readData(p)
+        case cv.inChunk:
+            cv.err = cv.endChunk()
+        case cv.done:
+            cv.err = io.EOF
+        default:
+            cv.err = cv.readHeader()
+        }
+    }
+
+    n := copy(p, cv.pending)
+    cv.pending = cv.pending[n:]
+    return n, nil
+}
+
+func (cv *chunkVerifier) Close() error {
+    return cv.body.Close()
+}
+
+// Lecture des données du chunk courant
+func (cv *chunkVerifier) readData(p []byte) (int, error) {
+    if int64(len(p)) > cv.remaining {
+        p = p[:cv.remaining]
+    }
+    n, err := cv.reader.Read(p)
+    cv.hash.Write(p[:n])
+    cv.remaining -= int64(n)
+    if err == io.EOF {
+        // Le corps s'arrête au milieu d'un chunk
+        err = ErrIncompleteBody
+    }
+    if err != nil {
+        cv.err = err
+    }
+    return n, err
+}
+
+// Fin des données d'un chunk : vérification de sa signature puis du CRLF qui le termine
+func (cv *chunkVerifier) endChunk() error {
+    if err := cv.verifySignature(hex.EncodeToString(cv.hash.Sum(nil))); err != nil {
+        return err
+    }
+
+    crlf := make([]byte, 2)
+    if _, err := io.ReadFull(cv.reader, crlf); err != nil || string(crlf) != "\r\n" {
+        return ErrChunkMalformed
+    }
+    cv.inChunk = false
+    cv.hash.Reset()
+    cv.pending = crlf
+    return nil
+}
+
+// Analyse de l'en-tête "taille-hex;chunk-signature=sig\r\n"
+func (cv *chunkVerifier) readHeader() error {
+    line, err := cv.readLine()
+    if err != nil {
+        return err
+    }
+
+    sizeHex, extension, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ";")
+    size, err := strconv.ParseInt(sizeHex, 16, 64)
+    if err != nil || size < 0 {
+        return ErrChunkMalformed
+    }
+    signature, found := strings.CutPrefix(extension, "chunk-signature=")
+    if !found || signature == "" {
+        return ErrChunkMalformed
+    }
+    cv.signature = signature
+
+    if size == 0 {
+        // Le dernier chunk est vide, sa signature porte sur le hash d'un contenu vide
+        if err := cv.verifySignature(EmptySHA256); err != nil {
+            return err
+        }
+        if cv.total != cv.decodedLength {
+            return ErrIncompleteBody
+        }
+        cv.done = true
+    } else {
+        cv.total += size
+        if cv.total > cv.decodedLength {
+            return ErrIncompleteBody
+        }
+        cv.inChunk = true
+        cv.remaining = size
+    }
+    cv.pending = []byte(line)
+    return nil
+}
+
+// Ligne d'en-tête de taille bornée, pour ne pas bufferiser un corps entier sans fin de ligne
+func (cv *chunkVerifier) readLine() (string, error) {
+    var line bytes.Buffer
+    for line.Len() < maxChunkHeaderLength {
+        b, err := cv.reader.ReadByte()
+        if err == io.EOF {
+            return "", ErrIncompleteBody
+        }
+        if err != nil {
+            return "", err
+        }
+        line.WriteByte(b)
+        if b == '\n' {
+            return line.String(), nil
+        }
+    }
+    return "", ErrChunkMalformed
+}
+
+// Signature chaînée : chaque chunk signe la signature du chunk précédent
+func (cv *chunkVerifier) verifySignature(chunkHash string) error {
+    expected := chunkSignature(cv.signingKey, cv.timestamp, cv.scope, cv.previousSignature, chunkHash)
+
+    if !hmac.Equal([]byte(expected), []byte(cv.signature)) {
+        return fmt.Errorf("chunk signature: %w", ErrSignatureDoesNotMatch)
+    }
+    cv.previousSignature = cv.signature
+    return nil
+}
+
+// Signature d'un chunk : HMAC de la chaîne à signer AWS4-HMAC-SHA256-PAYLOAD avec la clé de signature de la requête
+func chunkSignature(signingKey []byte, timestamp string, scope Scope, previousSignature, chunkHash string) string {
+    stringToSign := strings.Join([]string{
+        chunkAlgorithm,
+        timestamp,
+        scope.String(),
+        previousSignature,
+        EmptySHA256,
+        chunkHash,
+    }, "\n")
+    return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+// SignChunkedBody encode data au format aws-chunked en chunks de chunkSize octets,
+// signés en chaîne à partir de la signature de la requête (seedSignature). Utile pour les clients Go et les tests.
+func SignChunkedBody(data []byte, chunkSize int, secretKey string, scope Scope, t time.Time, seedSignature string) []byte {
+    signingKey := SigningKey(secretKey, scope)
+    timestamp := t.UTC().Format(TimeFormat)
+    previousSignature := seedSignature
+
+    var body bytes.Buffer
+    for {
+        n := chunkSize
+        if n > len(data) {
+            n = len(data)
+        }
+        chunk := data[:n]
+        data = data[n:]
+
+        hash := sha256.Sum256(chunk)
+        previousSignature = chunkSignature(signingKey, timestamp, scope, previousSignature, hex.EncodeToString(hash[:]))
+
+        fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n", n, previousSignature)
+        body.Write(chunk)
+        body.WriteString("\r\n")
+
+        if n == 0 {
+            return body.Bytes()
+        }
+    }
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+    "context"
+)
+
+type contextKey struct{}
+
+// NewContext retourne une copie de ctx portant le résultat de la vérification de la requête
+func NewContext(ctx context.Context, result *Result) context.Context {
+    return context.WithValue(ctx, contextKey{}, result)
+}
+
+// FromContext retourne le résultat de la vérification porté par ctx, ou nil si la requête n'a pas été authentifiée
+func FromContext(ctx context.Context) *Result {
+    result, _ := ctx.Value(contextKey{}).(*Result)
+    return result
+}
+
+// IsAdmin indique si la requête portée par ctx a été signée par une access key d'administration
+func (admins AdminAccessKeys) IsAdmin(ctx context.Context) bool {
+    result := FromContext(ctx)
+    return result != nil && admins[result.AccessKey]
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "hash"
+    "io"
+)
+
+// payloadVerifier calcule le SHA-256 du corps au fil de la lecture et renvoie
+// ErrContentSHA256Mismatch à la fin du flux s'il ne correspond pas à la valeur signée
+type payloadVerifier struct {
+    body     io.ReadCloser
+    hash     hash.Hash
+    expected string
+}
+
+// NewPayloadVerifier enveloppe body pour vérifier son SHA-256 (hexadécimal) à la lecture
+func NewPayloadVerifier(body io.ReadCloser, expectedSHA256 string) io.ReadCloser {
+    return &payloadVerifier{body: body, hash: sha256.New(), expected: expectedSHA256}
+}
+
+func (pv *payloadVerifier) Read(p []byte) (int, error) {
+    n, err := pv.body.Read(p)
+    pv.hash.Write(p[:n])
+    if err == io.EOF && hex.EncodeToString(pv.hash.Sum(nil)) != pv.expected {
+        return n, ErrContentSHA256Mismatch
+    }
+    return n, err
+}
+
+func (pv *payloadVerifier) Close() error {
+    return pv.body.Close()
+}
+
+// IsSignedPayload indique si la valeur de X-Amz-Content-Sha256 est un hash à vérifier
+func IsSignedPayload(contentSha256 string) bool {
+    if len(contentSha256) != sha256.Size*2 {
+        return false
+    }
+    _, err := hex.DecodeString(contentSha256)
+    return err == nil
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+    "crypto/hmac"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Durée de validité maximale d'une URL présignée (7 jours, comme S3)
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+var (
+    ErrAuthorizationQueryMalformed = errors.New("the presigned URL query parameters are malformed")
+    ErrRequestExpired              = errors.New("request has expired")
+    ErrRequestNotYetValid          = errors.New("request is not valid yet")
+)
+
+// Vérification d'une URL présignée : la signature est portée par les paramètres X-Amz-*,
+// le corps n'est jamais signé (UNSIGNED-PAYLOAD)
+func verifyPresignedRequest(r *http.Request, creds Credentials, now time.Time) (*Result, error) {
+    query := r.URL.Query()
+    if query.Get("X-Amz-Algorithm") != Algorithm {
+        return nil, ErrAuthorizationQueryMalformed
+    }
+
+    credential, err := parseCredential(query.Get("X-Amz-Credential"))
+    if err != nil {
+        return nil, ErrAuthorizationQueryMalformed
+    }
+    signedHeaders := strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+    if !containsString(signedHeaders, "host") {
+        return nil, ErrAuthorizationQueryMalformed
+    }
+    signature := query.Get("X-Amz-Signature")
+    if signature == "" {
+        return nil, ErrAuthorizationQueryMalformed
+    }
+
+    signedAt, err := time.Parse(TimeFormat, query.Get("X-Amz-Date"))
+    if err != nil || signedAt.Format(dateFormat) != credential.scope.Date {
+        return nil, ErrAuthorizationQueryMalformed
+    }
+    expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+    if err != nil || expires < 1 || time.Duration(expires)*time.Second > MaxPresignExpiry {
+        return nil, ErrAuthorizationQueryMalformed
+    }
+
+    secretKey, ok := creds[credential.accessKey]
+    if !ok {
+        return nil, ErrInvalidAccessKeyID
+    }
+
+    if now.Before(signedAt.Add(-MaxClockSkew)) {
+        return nil, ErrRequestNotYetValid
+    }
+    if now.After(signedAt.Add(time.Duration(expires) * time.Second)) {
+        return nil, ErrRequestExpired
+    }
+
+    canonicalRequest := CanonicalRequest(r, signedHeaders, UnsignedPayload)
+    signingKey := SigningKey(secretKey, credential.scope)
+    expected := hex.EncodeToString(hmacSHA256(signingKey, StringToSign(signedAt, credential.scope, canonicalRequest)))
+
+    if !hmac.Equal([]byte(expected), []byte(signature)) {
+        return nil, ErrSignatureDoesNotMatch
+    }
+
+    return &Result{
+        AccessKey:  credential.accessKey,
+        Scope:      credential.scope,
+        Time:       signedAt,
+        Signature:  signature,
+        SigningKey: signingKey,
+    }, nil
+}
+
+// PresignURL génère une URL présignée valable expires à partir de t, pour la méthode donnée
+// (GET/HEAD pour un téléchargement, PUT pour un envoi, DELETE pour une suppression).
+// rawURL est l'URL complète de l'objet, par exemple "http://localhost:9090/bucket/photos/chat.jpg".
+func PresignURL(method, rawURL, accessKey, secretKey, region string, t time.Time, expires time.Duration) (string, error) {
+    if expires < time.Second || expires > MaxPresignExpiry {
+        return "", fmt.Errorf("expiry must be between 1 second and %s", MaxPresignExpiry)
+    }
+
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return "", fmt.Errorf("invalid URL: %w", err)
+    }
+    if u.Host == "" {
+        return "", fmt.Errorf("invalid URL %q: missing host", rawURL)
+    }
+
+    t = t.UTC()
+    scope := Scope{Date: t.Format(dateFormat), Region: region, Service: "s3"}
+    signedHeaders := []string{"host"}
+
+    query := u.Query()
+    query.Set("X-Amz-Algorithm", Algorithm)
+    query.Set("X-Amz-Credential", accessKey+"/"+scope.String())
+    query.Set("X-Amz-Date", t.Format(TimeFormat))
+    query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+    query.Set("X-Amz-SignedHeaders", strings.Join(signedHeaders, ";"))
+    u.RawQuery = query.Encode()
+
+    req := &http.Request{Method: method, URL: u, Host: u.Host, Header: http.Header{}}
+    canonicalRequest := CanonicalRequest(req, signedHeaders, UnsignedPayload)
+    signature := hex.EncodeToString(hmacSHA256(SigningKey(secretKey, scope), StringToSign(t, scope, canonicalRequest)))
+
+    query.Set("X-Amz-Signature", signature)
+    u.RawQuery = query.Encode()
+    return u.String(), nil
+}
@@ -0,0 +1,378 @@
+package auth
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "net/url"
+    "os"
+    "sort"
+    "strings"
+    "time"
+)
+
+const (
+    // Algorithm est le seul schéma de signature accepté
+    Algorithm = "AWS4-HMAC-SHA256"
+
+    // Valeurs particulières de l'en-tête X-Amz-Content-Sha256
+    UnsignedPayload  = "UNSIGNED-PAYLOAD"
+    StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+    // Format des dates SigV4 (X-Amz-Date)
+    TimeFormat = "20060102T150405Z"
+    dateFormat = "20060102"
+
+    // Écart d'horloge maximal toléré entre le client et le serveur
+    MaxClockSkew = 15 * time.Minute
+
+    // SHA-256 d'un corps vide
+    EmptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Erreurs de vérification, chacune correspond à un code d'erreur S3
+var (
+    ErrMissingAuthentication  = errors.New("request is not signed")
+    ErrAuthorizationMalformed = errors.New("the authorization header is malformed")
+    ErrInvalidAccessKeyID     = errors.New("the AWS access key id you provided does not exist in our records")
+    ErrSignatureDoesNotMatch  = errors.New("the request signature we calculated does not match the signature you provided")
+    ErrRequestTimeTooSkewed   = errors.New("the difference between the request time and the server's time is too large")
+    ErrContentSHA256Mismatch  = errors.New("the provided x-amz-content-sha256 header does not match what was computed")
+    ErrMissingContentSHA256   = errors.New("missing required header for this request: x-amz-content-sha256")
+)
+
+// Credentials associe chaque access key à sa secret key
+type Credentials map[string]string
+
+// Identifiants utilisés lorsque S3_CREDENTIALS n'est pas défini
+var defaultCredentials = Credentials{"accessuser": "accesspassword"}
+
+// LoadCredentials lit les identifiants depuis S3_CREDENTIALS ("access1:secret1,access2:secret2").
+// Sans cette variable, les identifiants par défaut, connus de tous, sont utilisés : un avertissement est logué.
+func LoadCredentials() Credentials {
+    raw := strings.TrimSpace(os.Getenv("S3_CREDENTIALS"))
+    if raw == "" {
+        for accessKey := range defaultCredentials {
+            slog.Warn("S3_CREDENTIALS is not set, using the default credentials: set it before exposing the server", "access_key", accessKey)
+        }
+        return defaultCredentials
+    }
+
+    creds := Credentials{}
+    for _, pair := range strings.Split(raw, ",") {
+        accessKey, secretKey, ok := strings.Cut(strings.TrimSpace(pair), ":")
+        if !ok || accessKey == "" || secretKey == "" {
+            continue
+        }
+        creds[accessKey] = secretKey
+    }
+    if len(creds) == 0 {
+        slog.Warn("S3_CREDENTIALS contains no valid access1:secret1 pair, every request will be denied")
+    }
+    return creds
+}
+
+// AdminAccessKeys liste les access keys autorisées à utiliser les opérations d'administration
+// (suppression forcée d'un bucket...)
+type AdminAccessKeys map[string]bool
+
+// LoadAdminAccessKeys lit les access keys d'administration depuis S3_ADMIN_ACCESS_KEYS ("access1,access2").
+// Sans cette variable, aucune access key n'est administrateur.
+func LoadAdminAccessKeys() AdminAccessKeys {
+    admins := AdminAccessKeys{}
+    for _, accessKey := range strings.Split(os.Getenv("S3_ADMIN_ACCESS_KEYS"), ",") {
+        if accessKey = strings.TrimSpace(accessKey); accessKey != "" {
+            admins[accessKey] = true
+        }
+    }
+    return admins
+}
+
+// Scope est la portée d'une signature : date/région/service/aws4_request
+type Scope struct {
+    Date    string
+    Region  string
+    Service string
+}
+
+func (s Scope) String() string {
+    return strings.Join([]string{s.Date, s.Region, s.Service, "aws4_request"}, "/")
+}
+
+// Result décrit une requête dont la signature a été vérifiée
+type Result struct {
+    AccessKey  string
+    Scope      Scope
+    Time       time.Time
+    Signature  string
+    SigningKey []byte
+}
+
+// authorization est le contenu analysé de l'en-tête Authorization
+type authorization struct {
+    accessKey     string
+    scope         Scope
+    signedHeaders []string
+    signature     string
+}
+
+// VerifyRequest vérifie la signature SigV4 portée par l'en-tête Authorization,
+// ou par les paramètres de requête d'une URL présignée
+func VerifyRequest(r *http.Request, creds Credentials, now time.Time) (*Result, error) {
+    header := r.Header.Get("Authorization")
+    if header == "" {
+        if r.URL.Query().Has("X-Amz-Algorithm") {
+            return verifyPresignedRequest(r, creds, now)
+        }
+        return nil, ErrMissingAuthentication
+    }
+
+    auth, err := parseAuthorization(header)
+    if err != nil {
+        return nil, err
+    }
+
+    secretKey, ok := creds[auth.accessKey]
+    if !ok {
+        return nil, ErrInvalidAccessKeyID
+    }
+
+    requestTime, err := requestTime(r)
+    if err != nil {
+        return nil, err
+    }
+    if skew := now.Sub(requestTime); skew > MaxClockSkew || skew < -MaxClockSkew {
+        return nil, ErrRequestTimeTooSkewed
+    }
+    if requestTime.Format(dateFormat) != auth.scope.Date {
+        return nil, ErrSignatureDoesNotMatch
+    }
+
+    // Comme S3, le hash du corps est obligatoire : sans lui, une signature calculée pour un corps vide
+    // resterait valide quel que soit le corps envoyé
+    payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+    if payloadHash == "" {
+        return nil, ErrMissingContentSHA256
+    }
+
+    canonicalRequest := CanonicalRequest(r, auth.signedHeaders, payloadHash)
+    signingKey := SigningKey(secretKey, auth.scope)
+    expected := hex.EncodeToString(hmacSHA256(signingKey, StringToSign(requestTime, auth.scope, canonicalRequest)))
+
+    if !hmac.Equal([]byte(expected), []byte(auth.signature)) {
+        return nil, ErrSignatureDoesNotMatch
+    }
+
+    return &Result{
+        AccessKey:  auth.accessKey,
+        Scope:      auth.scope,
+        Time:       requestTime,
+        Signature:  auth.signature,
+        SigningKey: signingKey,
+    }, nil
+}
+
+// SignRequest signe une requête avec SigV4 (en-tête Authorization), utile pour les clients Go et les tests.
+// Si X-Amz-Content-Sha256 n'est pas défini, le corps n'est pas signé (UNSIGNED-PAYLOAD).
+func SignRequest(r *http.Request, accessKey, secretKey, region string, t time.Time) {
+    t = t.UTC()
+    r.Header.Set("X-Amz-Date", t.Format(TimeFormat))
+    if r.Header.Get("X-Amz-Content-Sha256") == "" {
+        r.Header.Set("X-Amz-Content-Sha256", UnsignedPayload)
+    }
+
+    signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+    scope := Scope{Date: t.Format(dateFormat), Region: region, Service: "s3"}
+
+    canonicalRequest := CanonicalRequest(r, signedHeaders, r.Header.Get("X-Amz-Content-Sha256"))
+    signature := hex.EncodeToString(hmacSHA256(SigningKey(secretKey, scope), StringToSign(t, scope, canonicalRequest)))
+
+    r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+        Algorithm, accessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// CanonicalRequest construit la requête canonique SigV4
+func CanonicalRequest(r *http.Request, signedHeaders []string, payloadHash string) string {
+    return strings.Join([]string{
+        r.Method,
+        canonicalURI(r.URL.Path),
+        canonicalQuery(r.URL.Query()),
+        canonicalHeaders(r, signedHeaders),
+        strings.Join(signedHeaders, ";"),
+        payloadHash,
+    }, "\n")
+}
+
+// StringToSign construit la chaîne à signer à partir de la requête canonique
+func StringToSign(t time.Time, scope Scope, canonicalRequest string) string {
+    hash := sha256.Sum256([]byte(canonicalRequest))
+    return strings.Join([]string{
+        Algorithm,
+        t.UTC().Format(TimeFormat),
+        scope.String(),
+        hex.EncodeToString(hash[:]),
+    }, "\n")
+}
+
+// SigningKey dérive la clé de signature : HMAC successifs de la date, la région, le service et "aws4_request"
+func SigningKey(secretKey string, scope Scope) []byte {
+    key := hmacSHA256([]byte("AWS4"+secretKey), scope.Date)
+    key = hmacSHA256(key, scope.Region)
+    key = hmacSHA256(key, scope.Service)
+    return hmacSHA256(key, "aws4_request")
+}
+
+// Analyse de "AWS4-HMAC-SHA256 Credential=AK/20240101/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=..."
+func parseAuthorization(header string) (authorization, error) {
+    var auth authorization
+
+    params, found := strings.CutPrefix(header, Algorithm+" ")
+    if !found {
+        return auth, ErrAuthorizationMalformed
+    }
+
+    for _, part := range strings.Split(params, ",") {
+        key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+        if !ok {
+            return auth, ErrAuthorizationMalformed
+        }
+        switch key {
+        case "Credential":
+            credential, err := parseCredential(value)
+            if err != nil {
+                return auth, err
+            }
+            auth.accessKey = credential.accessKey
+            auth.scope = credential.scope
+        case "SignedHeaders":
+            auth.signedHeaders = strings.Split(value, ";")
+        case "Signature":
+            auth.signature = value
+        }
+    }
+
+    if auth.accessKey == "" || auth.signature == "" || len(auth.signedHeaders) == 0 {
+        return auth, ErrAuthorizationMalformed
+    }
+    if !containsString(auth.signedHeaders, "host") {
+        return auth, ErrAuthorizationMalformed
+    }
+    return auth, nil
+}
+
+type credential struct {
+    accessKey string
+    scope     Scope
+}
+
+// Analyse de "AK/20240101/us-east-1/s3/aws4_request"
+func parseCredential(value string) (credential, error) {
+    parts := strings.Split(value, "/")
+    if len(parts) != 5 || parts[4] != "aws4_request" || parts[3] != "s3" {
+        return credential{}, ErrAuthorizationMalformed
+    }
+    if _, err := time.Parse(dateFormat, parts[1]); err != nil {
+        return credential{}, ErrAuthorizationMalformed
+    }
+    return credential{
+        accessKey: parts[0],
+        scope:     Scope{Date: parts[1], Region: parts[2], Service: parts[3]},
+    }, nil
+}
+
+// Date de la requête, depuis X-Amz-Date ou à défaut l'en-tête Date
+func requestTime(r *http.Request) (time.Time, error) {
+    if amzDate := r.Header.Get("X-Amz-Date"); amzDate != "" {
+        t, err := time.Parse(TimeFormat, amzDate)
+        if err != nil {
+            return time.Time{}, ErrAuthorizationMalformed
+        }
+        return t, nil
+    }
+    if date := r.Header.Get("Date"); date != "" {
+        t, err := http.ParseTime(date)
+        if err != nil {
+            return time.Time{}, ErrAuthorizationMalformed
+        }
+        return t, nil
+    }
+    return time.Time{}, ErrAuthorizationMalformed
+}
+
+// Chemin canonique : chaque segment est encodé, les "/" sont conservés
+func canonicalURI(path string) string {
+    if path == "" {
+        return "/"
+    }
+    segments := strings.Split(path, "/")
+    for i, segment := range segments {
+        segments[i] = uriEncode(segment)
+    }
+    return strings.Join(segments, "/")
+}
+
+// Paramètres de requête triés et encodés, la signature elle-même est exclue
+func canonicalQuery(query url.Values) string {
+    var pairs []string
+    for key, values := range query {
+        if key == "X-Amz-Signature" {
+            continue
+        }
+        for _, value := range values {
+            pairs = append(pairs, uriEncode(key)+"="+uriEncode(value))
+        }
+    }
+    sort.Strings(pairs)
+    return strings.Join(pairs, "&")
+}
+
+// En-têtes signés au format "nom:valeur\n", noms en minuscules et espaces normalisés
+func canonicalHeaders(r *http.Request, signedHeaders []string) string {
+    var builder strings.Builder
+    for _, name := range signedHeaders {
+        rawValues := r.Header.Values(name)
+        if name == "host" {
+            rawValues = []string{r.Host}
+        }
+        values := make([]string, len(rawValues))
+        for i, value := range rawValues {
+            values[i] = strings.Join(strings.Fields(value), " ")
+        }
+        builder.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+    }
+    return builder.String()
+}
+
+// Encodage URI selon SigV4 : seuls les caractères non réservés (RFC 3986) restent en clair
+func uriEncode(s string) string {
+    var builder strings.Builder
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+            c == '-' || c == '.' || c == '_' || c == '~' {
+            builder.WriteByte(c)
+        } else {
+            fmt.Fprintf(&builder, "%%%02X", c)
+        }
+    }
+    return builder.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(data))
+    return mac.Sum(nil)
+}
+
+func containsString(values []string, target string) bool {
+    for _, value := range values {
+        if value == target {
+            return true
+        }
+    }
+    return false
+}
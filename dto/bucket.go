@@ -13,7 +13,49 @@ type ListAllMyBucketsResult struct {
 type Bucket struct {
     Name         string    `xml:"Name"`
     CreationDate time.Time `xml:"CreationDate"`
-    LocationConstraint   string   `xml:"LocationConstraint,omitempty"`
-    ObjectLockConfig   string   `xml:"ObjectLockConfiguration,omitempty"`
-    ObjectDelimiter   string   `xml:"ObjectDelimiter,omitempty"`
+    BucketRegion string    `xml:"BucketRegion,omitempty"`
+}
+
+// BucketMetadata est l'enregistrement persisté à la création d'un bucket
+type BucketMetadata struct {
+    CreationDate time.Time `json:"creation_date"`
+    Region       string    `json:"region,omitempty"`
+    Owner        string    `json:"owner,omitempty"` // access key ayant créé le bucket
+}
+
+// BucketUsage est le nombre d'objets d'un bucket et leur taille totale
+type BucketUsage struct {
+    Objects int64
+    Bytes   int64
+}
+
+// CreateBucketConfiguration est le corps optionnel de PUT /bucket
+type CreateBucketConfiguration struct {
+    XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+    LocationConstraint string   `xml:"LocationConstraint"`
+}
+
+// LocationConstraint est la réponse de GET /bucket?location
+type LocationConstraint struct {
+    XMLName xml.Name `xml:"LocationConstraint"`
+    Region  string   `xml:",chardata"`
+}
+
+// VersioningConfiguration est la réponse de GET /bucket?versioning, sans Status : le versioning n'est pas géré
+type VersioningConfiguration struct {
+    XMLName xml.Name `xml:"VersioningConfiguration"`
+    Xmlns   string   `xml:"xmlns,attr"`
+    Status  string   `xml:"Status,omitempty"`
+}
+
+// BucketDeletionStatus décrit l'avancement de la suppression forcée d'un bucket
+type BucketDeletionStatus struct {
+    XMLName        xml.Name   `xml:"BucketDeletionStatus"`
+    Bucket         string     `xml:"Bucket"`
+    Status         string     `xml:"Status"` // InProgress, Completed ou Failed
+    ObjectsTotal   int64      `xml:"ObjectsTotal"`
+    ObjectsDeleted int64      `xml:"ObjectsDeleted"`
+    StartTime      time.Time  `xml:"StartTime"`
+    EndTime        *time.Time `xml:"EndTime,omitempty"`
+    Error          string     `xml:"Error,omitempty"`
 }
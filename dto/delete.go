@@ -3,25 +3,32 @@ package dto
 import (
     "encoding/xml"
 )
-// type DeleteObjectRequest struct {
-// 	Quiet  bool `xml:"Quiet"`
-// 	Object struct {
-// 		Key string `xml:"Key"`
-// 	} `xml:"Object"`
-// }
 
+// DeleteResult est la réponse de POST /bucket?delete : une entrée Deleted ou Error par clé,
+// seules les erreurs sont listées en mode Quiet
 type DeleteResult struct {
-	DeletedResult []Deleted `xml:"Deleted"`
+    XMLName       xml.Name      `xml:"DeleteResult"`
+    Xmlns         string        `xml:"xmlns,attr,omitempty"`
+    DeletedResult []Deleted     `xml:"Deleted"`
+    Errors        []DeleteError `xml:"Error"`
 }
 
 type Deleted struct {
 	Key string `xml:"Key"`
 }
 
+// DeleteError décrit l'échec de la suppression d'une clé
+type DeleteError struct {
+    Key     string `xml:"Key"`
+    Code    string `xml:"Code"`
+    Message string `xml:"Message"`
+}
+
 // DeleteObjectRequest représente la requête de suppression d'objets en batch
 type DeleteObjectRequest struct {
     XMLName xml.Name         `xml:"Delete"`
-    Objects []ObjectToDelete  `xml:"Object"`
+    Quiet   bool             `xml:"Quiet"`
+    Objects []ObjectToDelete `xml:"Object"`
 }
 
 // ObjectToDelete représente un objet à supprimer
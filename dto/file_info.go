@@ -6,17 +6,21 @@ import (
 )
 // FileInfo représente les métadonnées d'un fichier (objet)
 type FileInfo interface {
-    Name() string       // Nom de base du fichier
-    Size() int64        // Taille logique du fichier en octets
-    Mode() os.FileMode  // Informations sur le mode de fichier
-    ModTime() time.Time // Heure de dernière modification
-    IsDir() bool        // Indique si c'est un répertoire
-    Sys() interface{}   // Données spécifiques au système sous-jacent
+    Name() string             // Nom de base du fichier
+    Size() int64              // Taille logique du fichier en octets
+    Mode() os.FileMode        // Informations sur le mode de fichier
+    ModTime() time.Time       // Heure de dernière modification
+    IsDir() bool              // Indique si c'est un répertoire
+    Sys() interface{}         // Données spécifiques au système sous-jacent
+    ETag() string             // ETag de l'objet, entre guillemets
+    Metadata() ObjectMetadata // En-têtes enregistrés à l'envoi de l'objet
 }
 
 // FileInfoWrapper encapsule un os.FileInfo pour implémenter l'interface FileInfo
 type FileInfoWrapper struct {
-	FileInfo os.FileInfo
+	FileInfo      os.FileInfo
+	ETagValue     string
+	MetadataValue ObjectMetadata
 }
 
 // Implémentation des méthodes de l'interface FileInfo
@@ -44,3 +48,11 @@ func (fi *FileInfoWrapper) IsDir() bool {
 func (fi *FileInfoWrapper) Sys() interface{} {
 	return fi.FileInfo.Sys()
 }
+
+func (fi *FileInfoWrapper) ETag() string {
+	return fi.ETagValue
+}
+
+func (fi *FileInfoWrapper) Metadata() ObjectMetadata {
+	return fi.MetadataValue
+}
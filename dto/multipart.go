@@ -0,0 +1,81 @@
+package dto
+
+import (
+    "encoding/xml"
+    "time"
+)
+
+// InitiateMultipartUploadResult est la réponse de POST /bucket/key?uploads
+type InitiateMultipartUploadResult struct {
+    XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+    Xmlns    string   `xml:"xmlns,attr"`
+    Bucket   string   `xml:"Bucket"`
+    Key      string   `xml:"Key"`
+    UploadId string   `xml:"UploadId"`
+}
+
+// CompleteMultipartUpload est le corps de POST /bucket/key?uploadId=...
+type CompleteMultipartUpload struct {
+    XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+    Parts   []CompletedPart `xml:"Part"`
+}
+
+// CompletedPart désigne une partie à assembler
+type CompletedPart struct {
+    PartNumber int    `xml:"PartNumber"`
+    ETag       string `xml:"ETag"`
+}
+
+// CompleteMultipartUploadResult est la réponse à la finalisation d'un upload multipart
+type CompleteMultipartUploadResult struct {
+    XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+    Xmlns    string   `xml:"xmlns,attr"`
+    Location string   `xml:"Location"`
+    Bucket   string   `xml:"Bucket"`
+    Key      string   `xml:"Key"`
+    ETag     string   `xml:"ETag"`
+}
+
+// Part représente une partie déjà envoyée
+type Part struct {
+    PartNumber   int       `xml:"PartNumber"`
+    LastModified time.Time `xml:"LastModified"`
+    ETag         string    `xml:"ETag"`
+    Size         int64     `xml:"Size"`
+}
+
+// ListPartsResult est la réponse de GET /bucket/key?uploadId=...
+type ListPartsResult struct {
+    XMLName              xml.Name `xml:"ListPartsResult"`
+    Xmlns                string   `xml:"xmlns,attr"`
+    Bucket               string   `xml:"Bucket"`
+    Key                  string   `xml:"Key"`
+    UploadId             string   `xml:"UploadId"`
+    PartNumberMarker     int      `xml:"PartNumberMarker"`
+    NextPartNumberMarker int      `xml:"NextPartNumberMarker"`
+    MaxParts             int      `xml:"MaxParts"`
+    IsTruncated          bool     `xml:"IsTruncated"`
+    Parts                []Part   `xml:"Part"`
+}
+
+// MultipartUpload représente un upload multipart en cours
+type MultipartUpload struct {
+    Key       string    `xml:"Key"`
+    UploadId  string    `xml:"UploadId"`
+    Initiated time.Time `xml:"Initiated"`
+}
+
+// ListMultipartUploadsResult est la réponse de GET /bucket?uploads
+type ListMultipartUploadsResult struct {
+    XMLName            xml.Name          `xml:"ListMultipartUploadsResult"`
+    Xmlns              string            `xml:"xmlns,attr"`
+    Bucket             string            `xml:"Bucket"`
+    KeyMarker          string            `xml:"KeyMarker"`
+    UploadIdMarker     string            `xml:"UploadIdMarker"`
+    NextKeyMarker      string            `xml:"NextKeyMarker,omitempty"`
+    NextUploadIdMarker string            `xml:"NextUploadIdMarker,omitempty"`
+    Prefix             string            `xml:"Prefix"`
+    MaxUploads         int               `xml:"MaxUploads"`
+    IsTruncated        bool              `xml:"IsTruncated"`
+    Uploads            []MultipartUpload `xml:"Upload"`
+}
@@ -6,18 +6,67 @@ import (
 )
 
 type ListObjectsResponse struct {
-    XMLName     xml.Name `xml:"ListBucketResult"`
-    Xmlns       string   `xml:"xmlns,attr"`
-    Name        string   `xml:"Name"`
-    Prefix      string   `xml:"Prefix"`
-    Marker      string   `xml:"Marker"`
-    MaxKeys     int      `xml:"MaxKeys"`
-    IsTruncated bool     `xml:"IsTruncated"`
-    Contents    []Object `xml:"Contents"`
+    XMLName        xml.Name       `xml:"ListBucketResult"`
+    Xmlns          string         `xml:"xmlns,attr"`
+    Name           string         `xml:"Name"`
+    Prefix         string         `xml:"Prefix"`
+    Marker         string         `xml:"Marker"`
+    NextMarker     string         `xml:"NextMarker,omitempty"`
+    Delimiter      string         `xml:"Delimiter,omitempty"`
+    MaxKeys        int            `xml:"MaxKeys"`
+    IsTruncated    bool           `xml:"IsTruncated"`
+    Contents       []Object       `xml:"Contents"`
+    CommonPrefixes []CommonPrefix `xml:"CommonPrefixes"`
+}
+
+// CommonPrefix regroupe les clés partageant un même préfixe jusqu'au délimiteur
+type CommonPrefix struct {
+    Prefix string `xml:"Prefix"`
 }
 
 type Object struct {
     Key          string    `xml:"Key"`
     LastModified time.Time `xml:"LastModified"`
+    ETag         string    `xml:"ETag"`
     Size         int       `xml:"Size"`
+    Owner        *Owner    `xml:"Owner,omitempty"`
+}
+
+// CopyObjectResult est la réponse d'une copie (PUT /bucket/key avec x-amz-copy-source)
+type CopyObjectResult struct {
+    XMLName      xml.Name  `xml:"CopyObjectResult"`
+    Xmlns        string    `xml:"xmlns,attr"`
+    LastModified time.Time `xml:"LastModified"`
+    ETag         string    `xml:"ETag"`
+}
+
+// ObjectMetadata regroupe les en-têtes fournis à l'envoi d'un objet et renvoyés à sa lecture
+type ObjectMetadata struct {
+    ContentType        string            `json:"content_type,omitempty"`
+    ContentEncoding    string            `json:"content_encoding,omitempty"`
+    CacheControl       string            `json:"cache_control,omitempty"`
+    ContentDisposition string            `json:"content_disposition,omitempty"`
+    UserMetadata       map[string]string `json:"user_metadata,omitempty"` // x-amz-meta-*, noms en minuscules sans le préfixe
+}
+
+type Owner struct {
+    ID          string `xml:"ID"`
+    DisplayName string `xml:"DisplayName"`
+}
+
+// ListObjectsV2Response est la réponse de GET /bucket?list-type=2
+type ListObjectsV2Response struct {
+    XMLName               xml.Name       `xml:"ListBucketResult"`
+    Xmlns                 string         `xml:"xmlns,attr"`
+    Name                  string         `xml:"Name"`
+    Prefix                string         `xml:"Prefix"`
+    Delimiter             string         `xml:"Delimiter,omitempty"`
+    MaxKeys               int            `xml:"MaxKeys"`
+    KeyCount              int            `xml:"KeyCount"`
+    IsTruncated           bool           `xml:"IsTruncated"`
+    ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+    NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+    StartAfter            string         `xml:"StartAfter,omitempty"`
+    Contents              []Object       `xml:"Contents"`
+    CommonPrefixes        []CommonPrefix `xml:"CommonPrefixes"`
 }
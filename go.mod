@@ -4,7 +4,10 @@ go 1.21
 
 toolchain go1.23.0
 
-require github.com/minio/minio-go/v7 v7.0.76
+require (
+	github.com/gorilla/mux v1.8.1
+	github.com/minio/minio-go/v7 v7.0.76
+)
 
 require (
 	github.com/aws/aws-sdk-go v1.55.5 // indirect
@@ -13,7 +16,6 @@ require (
 	github.com/go-ini/ini v1.67.0 // indirect
 	github.com/goccy/go-json v0.10.3 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/gorilla/mux v1.8.1 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/klauspost/compress v1.17.9 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.8 // indirect
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+    "errors"
+    "log/slog"
+    "my-s3-clone/auth"
+    "my-s3-clone/dto"
+    "my-s3-clone/requestid"
+    "my-s3-clone/storage"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+// ForceDeleteHeader demande la suppression d'un bucket avec tout son contenu (réservé aux administrateurs)
+const ForceDeleteHeader = "x-s3clone-force-delete"
+
+// BucketDeletionStatusPath est la route servant l'avancement d'une suppression forcée
+const BucketDeletionStatusPath = "/_admin/bucket-deletions/{bucketName}"
+
+// États d'une suppression forcée
+const (
+    BucketDeletionInProgress = "InProgress"
+    BucketDeletionCompleted  = "Completed"
+    BucketDeletionFailed     = "Failed"
+)
+
+const (
+    // Nombre de clés listées (puis supprimées) à la fois
+    forceDeletePageSize = 1000
+    // Des objets peuvent être écrits pendant la suppression : le bucket est vidé au plus autant de fois
+    maxForceDeletePasses = 3
+)
+
+// BucketDeletions exécute en arrière-plan les suppressions forcées de buckets et conserve leur avancement.
+// L'état n'est gardé qu'en mémoire : il est perdu au redémarrage du serveur.
+type BucketDeletions struct {
+    s    storage.Storage
+    mu   sync.Mutex
+    jobs map[string]*bucketDeletion
+}
+
+type bucketDeletion struct {
+    mu     sync.Mutex
+    status dto.BucketDeletionStatus
+    done   chan struct{}
+}
+
+// NewBucketDeletions crée le suivi des suppressions forcées des buckets de s
+func NewBucketDeletions(s storage.Storage) *BucketDeletions {
+    return &BucketDeletions{s: s, jobs: make(map[string]*bucketDeletion)}
+}
+
+// Start lance la suppression forcée de bucketName, sauf si elle est déjà en cours, et retourne son état
+func (d *BucketDeletions) Start(bucketName string) dto.BucketDeletionStatus {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+
+    if job, ok := d.jobs[bucketName]; ok && job.snapshot().Status == BucketDeletionInProgress {
+        return job.snapshot()
+    }
+
+    job := &bucketDeletion{
+        status: dto.BucketDeletionStatus{Bucket: bucketName, Status: BucketDeletionInProgress, StartTime: time.Now().UTC()},
+        done:   make(chan struct{}),
+    }
+    d.jobs[bucketName] = job
+    go d.run(bucketName, job)
+    return job.snapshot()
+}
+
+// Status retourne l'état de la dernière suppression forcée de bucketName
+func (d *BucketDeletions) Status(bucketName string) (dto.BucketDeletionStatus, bool) {
+    d.mu.Lock()
+    job, ok := d.jobs[bucketName]
+    d.mu.Unlock()
+    if !ok {
+        return dto.BucketDeletionStatus{}, false
+    }
+    return job.snapshot(), true
+}
+
+// Wait attend la fin de la dernière suppression forcée de bucketName et retourne son état
+func (d *BucketDeletions) Wait(bucketName string) (dto.BucketDeletionStatus, bool) {
+    d.mu.Lock()
+    job, ok := d.jobs[bucketName]
+    d.mu.Unlock()
+    if !ok {
+        return dto.BucketDeletionStatus{}, false
+    }
+    <-job.done
+    return job.snapshot(), true
+}
+
+func (d *BucketDeletions) run(bucketName string, job *bucketDeletion) {
+    defer close(job.done)
+    logger := slog.Default().With("bucket", bucketName)
+    logger.Info("forced bucket deletion started")
+
+    total, err := d.countObjects(bucketName)
+    if err == nil {
+        job.update(func(status *dto.BucketDeletionStatus) { status.ObjectsTotal = total })
+        err = d.purge(bucketName, job, logger)
+    }
+
+    job.update(func(status *dto.BucketDeletionStatus) {
+        end := time.Now().UTC()
+        status.EndTime = &end
+        status.Status = BucketDeletionCompleted
+        if err != nil {
+            status.Status = BucketDeletionFailed
+            status.Error = err.Error()
+        }
+    })
+
+    status := job.snapshot()
+    if err != nil {
+        logger.Error("forced bucket deletion failed", "deleted", status.ObjectsDeleted, "total", status.ObjectsTotal, "error", err)
+        return
+    }
+    logger.Info("forced bucket deletion completed", "deleted", status.ObjectsDeleted, "duration", status.EndTime.Sub(status.StartTime))
+}
+
+// Nombre d'objets du bucket au lancement de la suppression, pour situer l'avancement
+func (d *BucketDeletions) countObjects(bucketName string) (int64, error) {
+    var total int64
+    marker := ""
+    for {
+        page, err := d.s.ListObjects(bucketName, "", marker, "", forceDeletePageSize)
+        if err != nil {
+            return 0, err
+        }
+        total += int64(len(page.Contents))
+        if !page.IsTruncated || len(page.Contents) == 0 {
+            return total, nil
+        }
+        marker = page.Contents[len(page.Contents)-1].Key
+    }
+}
+
+// Vidage puis suppression du bucket. Les objets écrits pendant le vidage font échouer DeleteBucket
+// avec ErrBucketNotEmpty : le bucket est alors vidé à nouveau.
+func (d *BucketDeletions) purge(bucketName string, job *bucketDeletion, logger *slog.Logger) error {
+    for pass := 1; ; pass++ {
+        if err := d.deleteObjects(bucketName, job, logger); err != nil {
+            return err
+        }
+
+        uploads, err := d.s.ListMultipartUploads(bucketName)
+        if err != nil {
+            return err
+        }
+        for _, upload := range uploads {
+            if err := d.s.AbortMultipartUpload(bucketName, upload.Key, upload.UploadId); err != nil && !errors.Is(err, storage.ErrNoSuchUpload) {
+                return err
+            }
+        }
+
+        err = d.s.DeleteBucket(bucketName)
+        if errors.Is(err, storage.ErrBucketNotEmpty) && pass < maxForceDeletePasses {
+            logger.Warn("objects were added during the forced deletion, emptying the bucket again", "pass", pass)
+            continue
+        }
+        return err
+    }
+}
+
+func (d *BucketDeletions) deleteObjects(bucketName string, job *bucketDeletion, logger *slog.Logger) error {
+    // Le marker avance même si une clé n'a pas pu être supprimée, ce qui garantit que le parcours se termine ;
+    // les clés ajoutées derrière lui sont traitées par la passe suivante (voir purge)
+    marker := ""
+    for {
+        page, err := d.s.ListObjects(bucketName, "", marker, "", forceDeletePageSize)
+        if err != nil {
+            return err
+        }
+        if len(page.Contents) == 0 {
+            return nil
+        }
+        marker = page.Contents[len(page.Contents)-1].Key
+
+        for _, object := range page.Contents {
+            if err := d.s.DeleteObject(bucketName, object.Key); err != nil && !errors.Is(err, storage.ErrObjectNotFound) {
+                return err
+            }
+            job.update(func(status *dto.BucketDeletionStatus) { status.ObjectsDeleted++ })
+        }
+
+        status := job.snapshot()
+        logger.Info("forced bucket deletion progress", "deleted", status.ObjectsDeleted, "total", status.ObjectsTotal)
+        if !page.IsTruncated {
+            return nil
+        }
+    }
+}
+
+func (job *bucketDeletion) update(apply func(status *dto.BucketDeletionStatus)) {
+    job.mu.Lock()
+    defer job.mu.Unlock()
+    apply(&job.status)
+}
+
+func (job *bucketDeletion) snapshot() dto.BucketDeletionStatus {
+    job.mu.Lock()
+    defer job.mu.Unlock()
+    return job.status
+}
+
+// Force delete a bucket with all of its objects (DELETE /bucket/ with x-s3clone-force-delete: true).
+// Only admin access keys may do so; the deletion runs in the background and 202 Accepted is returned
+// with its status, which can then be polled on BucketDeletionStatusPath.
+func HandleForceDeleteBucket(d *BucketDeletions, admins auth.AdminAccessKeys) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
+        bucketName := mux.Vars(r)["bucketName"]
+
+        if !admins.IsAdmin(r.Context()) {
+            logger.Warn("forced bucket deletion refused to a non-admin access key", "bucket", bucketName)
+            WriteError(w, r, storage.ErrAccessDenied)
+            return
+        }
+
+        exists, err := d.s.CheckBucketExists(bucketName)
+        if err != nil {
+            WriteError(w, r, err)
+            return
+        }
+        if !exists {
+            WriteError(w, r, storage.ErrNoSuchBucket)
+            return
+        }
+
+        status := d.Start(bucketName)
+        logger.Info("forced bucket deletion requested", "bucket", bucketName)
+        w.Header().Set("Location", strings.Replace(BucketDeletionStatusPath, "{bucketName}", bucketName, 1))
+        writeXML(w, r, http.StatusAccepted, status)
+    }
+}
+
+// Progress of the last forced deletion of a bucket (GET /_admin/bucket-deletions/{bucketName}), admin only
+func HandleBucketDeletionStatus(d *BucketDeletions, admins auth.AdminAccessKeys) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        bucketName := mux.Vars(r)["bucketName"]
+
+        if !admins.IsAdmin(r.Context()) {
+            WriteError(w, r, storage.ErrAccessDenied)
+            return
+        }
+
+        status, ok := d.Status(bucketName)
+        if !ok {
+            WriteError(w, r, storage.ErrNoSuchBucket.WithMessage("No forced deletion was started for the specified bucket."))
+            return
+        }
+        writeXML(w, r, http.StatusOK, status)
+    }
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+    "my-s3-clone/dto"
+    "my-s3-clone/requestid"
+    "my-s3-clone/storage"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+// Copy an object server-side (PUT /bucket/key with x-amz-copy-source)
+func HandleCopyObject(s storage.Storage) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
+
+        vars := mux.Vars(r)
+        bucketName := vars["bucketName"]
+        objectName := vars["objectName"]
+
+        srcBucket, srcObject, err := parseCopySource(r.Header.Get("X-Amz-Copy-Source"))
+        if err != nil {
+            WriteError(w, r, err)
+            return
+        }
+
+        // COPY (par défaut) reprend les métadonnées de la source, REPLACE utilise celles de la requête
+        var meta *dto.ObjectMetadata
+        switch directive := r.Header.Get("X-Amz-Metadata-Directive"); directive {
+        case "", "COPY":
+            if srcBucket == bucketName && srcObject == objectName {
+                WriteError(w, r, storage.ErrInvalidRequest.WithMessage("This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes."))
+                return
+            }
+        case "REPLACE":
+            replaced, err := objectMetadataFromHeaders(r.Header)
+            if err != nil {
+                WriteError(w, r, err)
+                return
+            }
+            meta = &replaced
+        default:
+            WriteError(w, r, storage.ErrInvalidArgument.WithMessage("Unknown metadata directive."))
+            return
+        }
+
+        exists, srcInfo, err := s.CheckObjectExist(srcBucket, srcObject)
+        if err != nil {
+            WriteError(w, r, err)
+            return
+        }
+        if !exists {
+            WriteError(w, r, storage.ErrObjectNotFound)
+            return
+        }
+        if !copyPreconditionsHold(r.Header, srcInfo) {
+            WriteError(w, r, storage.ErrPreconditionFailed)
+            return
+        }
+
+        info, err := s.CopyObject(srcBucket, srcObject, bucketName, objectName, meta)
+        if err != nil {
+            logger.Warn("could not copy object", "from", srcBucket+"/"+srcObject, "bucket", bucketName, "key", objectName, "error", err)
+            WriteError(w, r, err)
+            return
+        }
+
+        logger.Info("object copied", "from", srcBucket+"/"+srcObject, "bucket", bucketName, "key", objectName, "etag", info.ETag())
+        writeXML(w, r, http.StatusOK, dto.CopyObjectResult{
+            Xmlns:        "http://s3.amazonaws.com/doc/2006-03-01/",
+            LastModified: info.ModTime().UTC(),
+            ETag:         info.ETag(),
+        })
+    }
+}
+
+// Décodage de x-amz-copy-source ("bucket/key" ou "/bucket/key", encodé comme une URL).
+// Le versioning n'étant pas géré, seul versionId=null est accepté.
+func parseCopySource(source string) (string, string, error) {
+    path, query, _ := strings.Cut(source, "?")
+    if query != "" {
+        values, err := url.ParseQuery(query)
+        if err != nil || (values.Get("versionId") != "" && values.Get("versionId") != "null") {
+            return "", "", storage.ErrInvalidArgument.WithMessage("Copy Source must mention the source bucket and key: sourcebucket/sourcekey.")
+        }
+    }
+
+    decoded, err := url.PathUnescape(path)
+    if err != nil {
+        return "", "", storage.ErrInvalidArgument.WithMessage("Copy Source must mention the source bucket and key: sourcebucket/sourcekey.")
+    }
+    bucket, key, ok := strings.Cut(strings.TrimPrefix(decoded, "/"), "/")
+    if !ok || bucket == "" || key == "" {
+        return "", "", storage.ErrInvalidArgument.WithMessage("Copy Source must mention the source bucket and key: sourcebucket/sourcekey.")
+    }
+    return bucket, key, nil
+}
+
+// Évaluation des en-têtes x-amz-copy-source-if-* sur l'objet source, avec les règles de S3 :
+// un if-match satisfait l'emporte sur if-unmodified-since, un if-none-match non satisfait
+// l'emporte sur if-modified-since
+func copyPreconditionsHold(h http.Header, src dto.FileInfo) bool {
+    modTime := src.ModTime().Truncate(time.Second)
+
+    if ifMatch := h.Get("X-Amz-Copy-Source-If-Match"); ifMatch != "" {
+        if !etagMatches(ifMatch, src.ETag()) {
+            return false
+        }
+    } else if t, err := http.ParseTime(h.Get("X-Amz-Copy-Source-If-Unmodified-Since")); err == nil && modTime.After(t) {
+        return false
+    }
+
+    if ifNoneMatch := h.Get("X-Amz-Copy-Source-If-None-Match"); ifNoneMatch != "" {
+        if etagMatches(ifNoneMatch, src.ETag()) {
+            return false
+        }
+    } else if t, err := http.ParseTime(h.Get("X-Amz-Copy-Source-If-Modified-Since")); err == nil && !modTime.After(t) {
+        return false
+    }
+    return true
+}
+
+// Comparaison d'une liste d'ETags (séparés par des virgules, "*" pour tous) avec l'ETag d'un objet
+func etagMatches(list, etag string) bool {
+    for _, candidate := range strings.Split(list, ",") {
+        candidate = strings.TrimSpace(candidate)
+        if candidate == "*" || strings.Trim(candidate, `"`) == strings.Trim(etag, `"`) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+    "errors"
+    "my-s3-clone/auth"
+    "my-s3-clone/dto"
+    "my-s3-clone/requestid"
+    "my-s3-clone/storage"
+    "net/http"
+
+    "github.com/gorilla/mux"
+)
+
+// WriteError sends err as an S3 XML error body. Errors from the storage catalogue keep their code and status,
+// authentication errors are translated, anything else becomes an InternalError.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+    apiErr := toAPIError(err)
+    logger := requestid.Logger(r.Context())
+
+    // Hors du routeur (tests de handlers isolés), aucun identifiant n'a encore été attribué
+    requestID := requestid.FromContext(r.Context())
+    if requestID == "" {
+        requestID = requestid.New()
+    }
+    hostID := requestid.HostID()
+
+    vars := mux.Vars(r)
+    response := dto.ErrorResponse{
+        Code:       apiErr.Code,
+        Message:    apiErr.Message,
+        BucketName: vars["bucketName"],
+        Key:        vars["objectName"],
+        Resource:   r.URL.Path,
+        RequestId:  requestID,
+        HostId:     hostID,
+    }
+
+    // Le détail des erreurs internes reste dans les logs (NotImplemented répond à une demande du client)
+    if apiErr.StatusCode >= http.StatusInternalServerError && apiErr.StatusCode != http.StatusNotImplemented {
+        logger.Error("internal error", "method", r.Method, "path", r.URL.Path, "error", err)
+    }
+
+    w.Header().Set("x-amz-request-id", requestID)
+    w.Header().Set("x-amz-id-2", hostID)
+    writeXML(w, r, apiErr.StatusCode, response)
+}
+
+// Erreur du catalogue correspondant à err
+func toAPIError(err error) *storage.APIError {
+    var apiErr *storage.APIError
+    if errors.As(err, &apiErr) {
+        return apiErr
+    }
+
+    switch {
+    case errors.Is(err, auth.ErrMissingAuthentication):
+        return storage.ErrAccessDenied
+    case errors.Is(err, auth.ErrRequestExpired):
+        return storage.ErrAccessDenied.WithMessage("Request has expired.")
+    case errors.Is(err, auth.ErrRequestNotYetValid):
+        return storage.ErrAccessDenied.WithMessage("Request is not valid yet.")
+    case errors.Is(err, auth.ErrAuthorizationMalformed):
+        return storage.ErrAuthorizationHeaderMalformed
+    case errors.Is(err, auth.ErrAuthorizationQueryMalformed):
+        return storage.ErrAuthorizationQueryParametersError
+    case errors.Is(err, auth.ErrInvalidAccessKeyID):
+        return storage.ErrInvalidAccessKeyID
+    case errors.Is(err, auth.ErrSignatureDoesNotMatch):
+        return storage.ErrSignatureDoesNotMatch
+    case errors.Is(err, auth.ErrRequestTimeTooSkewed):
+        return storage.ErrRequestTimeTooSkewed
+    case errors.Is(err, auth.ErrMissingContentLength):
+        return storage.ErrMissingContentLength
+    case errors.Is(err, auth.ErrContentSHA256Mismatch):
+        return storage.ErrXAmzContentSHA256Mismatch
+    case errors.Is(err, auth.ErrMissingContentSHA256):
+        return storage.ErrInvalidRequest.WithMessage("Missing required header for this request: x-amz-content-sha256.")
+    case errors.Is(err, auth.ErrIncompleteBody):
+        return storage.ErrIncompleteBody
+    case errors.Is(err, auth.ErrChunkMalformed):
+        return storage.ErrInvalidRequest.WithMessage("The aws-chunked body is malformed.")
+    }
+    return storage.ErrInternalError
+}
+
+// Handler for requests whose method is not supported on the matched route
+func MethodNotAllowedHandler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        WriteError(w, r, storage.ErrMethodNotAllowed)
+    })
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+    "my-s3-clone/dto"
+    "my-s3-clone/storage"
+    "net/http"
+    "strings"
+)
+
+// Préfixe des en-têtes de métadonnées utilisateur
+const userMetadataPrefix = "x-amz-meta-"
+
+// Taille maximale des métadonnées utilisateur (noms et valeurs), comme sur S3
+const maxUserMetadataSize = 2 << 10
+
+// Type de contenu renvoyé pour les objets envoyés sans Content-Type
+const defaultContentType = "application/octet-stream"
+
+// Lecture des métadonnées fournies à l'envoi d'un objet (PUT ou création d'un upload multipart)
+func objectMetadataFromHeaders(h http.Header) (dto.ObjectMetadata, error) {
+    meta := dto.ObjectMetadata{
+        ContentType:        h.Get("Content-Type"),
+        ContentEncoding:    storedContentEncoding(h.Values("Content-Encoding")),
+        CacheControl:       h.Get("Cache-Control"),
+        ContentDisposition: h.Get("Content-Disposition"),
+    }
+
+    size := 0
+    for name, values := range h {
+        lowerName := strings.ToLower(name)
+        if !strings.HasPrefix(lowerName, userMetadataPrefix) || len(lowerName) == len(userMetadataPrefix) {
+            continue
+        }
+        if meta.UserMetadata == nil {
+            meta.UserMetadata = make(map[string]string)
+        }
+        key := strings.TrimPrefix(lowerName, userMetadataPrefix)
+        // Comme S3, un en-tête répété est restitué sous forme de liste séparée par des virgules
+        value := strings.Join(values, ",")
+        meta.UserMetadata[key] = value
+        size += len(key) + len(value)
+    }
+    if size > maxUserMetadataSize {
+        return dto.ObjectMetadata{}, storage.ErrMetadataTooLarge
+    }
+    return meta, nil
+}
+
+// Content-Encoding à enregistrer avec l'objet. "aws-chunked" ne décrit que le transport d'un upload
+// streaming, dont le corps est décodé avant stockage : seuls les autres codages sont conservés.
+func storedContentEncoding(values []string) string {
+    var encodings []string
+    for _, value := range values {
+        for _, encoding := range strings.Split(value, ",") {
+            encoding = strings.TrimSpace(encoding)
+            if encoding != "" && !strings.EqualFold(encoding, "aws-chunked") {
+                encodings = append(encodings, encoding)
+            }
+        }
+    }
+    return strings.Join(encodings, ",")
+}
+
+// Restitution des métadonnées d'un objet dans les en-têtes d'une réponse GET ou HEAD
+func setObjectMetadataHeaders(h http.Header, meta dto.ObjectMetadata) {
+    contentType := meta.ContentType
+    if contentType == "" {
+        contentType = defaultContentType
+    }
+    h.Set("Content-Type", contentType)
+
+    if meta.ContentEncoding != "" {
+        h.Set("Content-Encoding", meta.ContentEncoding)
+    }
+    if meta.CacheControl != "" {
+        h.Set("Cache-Control", meta.CacheControl)
+    }
+    if meta.ContentDisposition != "" {
+        h.Set("Content-Disposition", meta.ContentDisposition)
+    }
+    // Les noms sont renvoyés en minuscules, comme les renvoie S3
+    for key, value := range meta.UserMetadata {
+        h[userMetadataPrefix+key] = []string{value}
+    }
+}
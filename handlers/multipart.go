@@ -0,0 +1,276 @@
+package handlers
+
+import (
+    "encoding/xml"
+    "io"
+    "my-s3-clone/dto"
+    "my-s3-clone/requestid"
+    "my-s3-clone/storage"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gorilla/mux"
+)
+
+// Numéro de partie maximal accepté par S3
+const maxPartNumber = 10000
+
+// Initiate a multipart upload (POST /bucket/key?uploads)
+func HandleCreateMultipartUpload(s storage.Storage) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
+
+        vars := mux.Vars(r)
+        bucketName := vars["bucketName"]
+        objectName := vars["objectName"]
+
+        meta, err := objectMetadataFromHeaders(r.Header)
+        if err != nil {
+            WriteError(w, r, err)
+            return
+        }
+
+        uploadID, err := s.CreateMultipartUpload(bucketName, objectName, meta)
+        if err != nil {
+            logger.Warn("could not create multipart upload", "bucket", bucketName, "key", objectName, "error", err)
+            WriteError(w, r, err)
+            return
+        }
+
+        writeXML(w, r, http.StatusOK, dto.InitiateMultipartUploadResult{
+            Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
+            Bucket:   bucketName,
+            Key:      objectName,
+            UploadId: uploadID,
+        })
+    }
+}
+
+// Upload one part (PUT /bucket/key?partNumber=N&uploadId=ID)
+func HandleUploadPart(s storage.Storage) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
+
+        vars := mux.Vars(r)
+        bucketName := vars["bucketName"]
+        objectName := vars["objectName"]
+        uploadID := r.URL.Query().Get("uploadId")
+
+        partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+        if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+            WriteError(w, r, storage.ErrInvalidArgument.WithMessage("Part number must be an integer between 1 and 10000, inclusive."))
+            return
+        }
+
+        eTag, err := s.UploadPart(bucketName, objectName, uploadID, partNumber, r.Body, r.Header.Get("X-Amz-Content-Sha256"))
+        if err != nil {
+            logger.Warn("could not upload part", "upload_id", uploadID, "part_number", partNumber, "error", err)
+            WriteError(w, r, err)
+            return
+        }
+
+        w.Header().Set("ETag", eTag)
+        w.WriteHeader(http.StatusOK)
+    }
+}
+
+// Complete a multipart upload (POST /bucket/key?uploadId=ID)
+func HandleCompleteMultipartUpload(s storage.Storage) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
+
+        vars := mux.Vars(r)
+        bucketName := vars["bucketName"]
+        objectName := vars["objectName"]
+        uploadID := r.URL.Query().Get("uploadId")
+
+        var completeReq dto.CompleteMultipartUpload
+        if err := xml.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&completeReq); err != nil || len(completeReq.Parts) == 0 {
+            logger.Warn("invalid CompleteMultipartUpload body", "upload_id", uploadID, "error", err)
+            WriteError(w, r, storage.ErrMalformedXML)
+            return
+        }
+
+        eTag, err := s.CompleteMultipartUpload(bucketName, objectName, uploadID, completeReq.Parts)
+        if err != nil {
+            logger.Warn("could not complete multipart upload", "upload_id", uploadID, "error", err)
+            WriteError(w, r, err)
+            return
+        }
+
+        writeXML(w, r, http.StatusOK, dto.CompleteMultipartUploadResult{
+            Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
+            Location: "/" + bucketName + "/" + objectName,
+            Bucket:   bucketName,
+            Key:      objectName,
+            ETag:     eTag,
+        })
+    }
+}
+
+// Abort a multipart upload (DELETE /bucket/key?uploadId=ID)
+func HandleAbortMultipartUpload(s storage.Storage) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
+
+        vars := mux.Vars(r)
+        uploadID := r.URL.Query().Get("uploadId")
+
+        if err := s.AbortMultipartUpload(vars["bucketName"], vars["objectName"], uploadID); err != nil {
+            logger.Warn("could not abort multipart upload", "upload_id", uploadID, "error", err)
+            WriteError(w, r, err)
+            return
+        }
+
+        w.WriteHeader(http.StatusNoContent)
+    }
+}
+
+// List the parts of a multipart upload (GET /bucket/key?uploadId=ID)
+func HandleListParts(s storage.Storage) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
+        vars := mux.Vars(r)
+        bucketName := vars["bucketName"]
+        objectName := vars["objectName"]
+        queryParams := r.URL.Query()
+        uploadID := queryParams.Get("uploadId")
+
+        partNumberMarker, maxParts, ok := parseMarkerAndMax(queryParams.Get("part-number-marker"), queryParams.Get("max-parts"))
+        if !ok {
+            WriteError(w, r, storage.ErrInvalidArgument.WithMessage("Invalid part-number-marker or max-parts value."))
+            return
+        }
+
+        parts, err := s.ListParts(bucketName, objectName, uploadID)
+        if err != nil {
+            logger.Warn("could not list parts", "upload_id", uploadID, "error", err)
+            WriteError(w, r, err)
+            return
+        }
+
+        response := dto.ListPartsResult{
+            Xmlns:            "http://s3.amazonaws.com/doc/2006-03-01/",
+            Bucket:           bucketName,
+            Key:              objectName,
+            UploadId:         uploadID,
+            PartNumberMarker: partNumberMarker,
+            MaxParts:         maxParts,
+            Parts:            make([]dto.Part, 0),
+        }
+        for _, part := range parts {
+            if part.PartNumber <= partNumberMarker {
+                continue
+            }
+            if len(response.Parts) >= maxParts {
+                response.IsTruncated = true
+                break
+            }
+            response.Parts = append(response.Parts, part)
+            response.NextPartNumberMarker = part.PartNumber
+        }
+
+        writeXML(w, r, http.StatusOK, response)
+    }
+}
+
+// List the in-progress multipart uploads of a bucket (GET /bucket?uploads)
+func HandleListMultipartUploads(s storage.Storage) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
+        vars := mux.Vars(r)
+        bucketName := vars["bucketName"]
+        queryParams := r.URL.Query()
+        prefix := queryParams.Get("prefix")
+        keyMarker := queryParams.Get("key-marker")
+        uploadIDMarker := queryParams.Get("upload-id-marker")
+
+        _, maxUploads, ok := parseMarkerAndMax("", queryParams.Get("max-uploads"))
+        if !ok {
+            WriteError(w, r, storage.ErrInvalidArgument.WithMessage("Invalid max-uploads value."))
+            return
+        }
+
+        uploads, err := s.ListMultipartUploads(bucketName)
+        if err != nil {
+            logger.Warn("could not list multipart uploads", "bucket", bucketName, "error", err)
+            WriteError(w, r, err)
+            return
+        }
+
+        response := dto.ListMultipartUploadsResult{
+            Xmlns:          "http://s3.amazonaws.com/doc/2006-03-01/",
+            Bucket:         bucketName,
+            KeyMarker:      keyMarker,
+            UploadIdMarker: uploadIDMarker,
+            Prefix:         prefix,
+            MaxUploads:     maxUploads,
+            Uploads:        make([]dto.MultipartUpload, 0),
+        }
+
+        // Sans upload-id-marker, tous les uploads de key-marker sont ignorés ;
+        // avec, seuls ceux qui précèdent upload-id-marker (inclus) le sont
+        afterUploadIDMarker := false
+        for _, upload := range uploads {
+            if !strings.HasPrefix(upload.Key, prefix) || upload.Key < keyMarker {
+                continue
+            }
+            if upload.Key == keyMarker && (uploadIDMarker == "" || !afterUploadIDMarker) {
+                afterUploadIDMarker = upload.UploadId == uploadIDMarker
+                continue
+            }
+            if len(response.Uploads) >= maxUploads {
+                response.IsTruncated = true
+                break
+            }
+            response.Uploads = append(response.Uploads, upload)
+            response.NextKeyMarker = upload.Key
+            response.NextUploadIdMarker = upload.UploadId
+        }
+        if !response.IsTruncated {
+            response.NextKeyMarker = ""
+            response.NextUploadIdMarker = ""
+        }
+
+        writeXML(w, r, http.StatusOK, response)
+    }
+}
+
+// Parse an integer marker and a page size bounded to 1000 like S3 does
+func parseMarkerAndMax(markerStr, maxStr string) (int, int, bool) {
+    marker := 0
+    if markerStr != "" {
+        var err error
+        if marker, err = strconv.Atoi(markerStr); err != nil || marker < 0 {
+            return 0, 0, false
+        }
+    }
+
+    max := 1000
+    if maxStr != "" {
+        var err error
+        if max, err = strconv.Atoi(maxStr); err != nil || max < 0 {
+            return 0, 0, false
+        }
+        if max > 1000 {
+            max = 1000
+        }
+    }
+    return marker, max, true
+}
+
+
+// Encode an XML response body
+func writeXML(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+    logger := requestid.Logger(r.Context())
+    w.Header().Set("Content-Type", "application/xml")
+    w.WriteHeader(status)
+    if err := xml.NewEncoder(w).Encode(v); err != nil {
+        logger.Error("could not encode XML response", "error", err)
+    }
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+)
+
+// errUnsatisfiableRange indique qu'un en-tête Range valide ne recouvre aucun octet de l'objet
+var errUnsatisfiableRange = errors.New("requested range not satisfiable")
+
+// byteRange représente une plage d'octets résolue sur un objet de taille connue
+type byteRange struct {
+    start  int64
+    length int64
+}
+
+// parseRange interprète un en-tête Range de la forme "bytes=debut-fin", "bytes=debut-" ou "bytes=-suffixe".
+// Comme S3, les en-têtes mal formés ou demandant plusieurs plages sont ignorés (ok == false)
+// et l'objet est servi en entier.
+func parseRange(header string, size int64) (rng byteRange, ok bool, err error) {
+    spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+    if !found || spec == "" || strings.Contains(spec, ",") {
+        return byteRange{}, false, nil
+    }
+
+    startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+    if !found {
+        return byteRange{}, false, nil
+    }
+
+    // Plage suffixe : les N derniers octets
+    if startStr == "" {
+        suffix, err := strconv.ParseInt(endStr, 10, 64)
+        if err != nil || suffix < 0 {
+            return byteRange{}, false, nil
+        }
+        if suffix == 0 || size == 0 {
+            return byteRange{}, true, errUnsatisfiableRange
+        }
+        if suffix > size {
+            suffix = size
+        }
+        return byteRange{start: size - suffix, length: suffix}, true, nil
+    }
+
+    start, err := strconv.ParseInt(startStr, 10, 64)
+    if err != nil || start < 0 {
+        return byteRange{}, false, nil
+    }
+
+    end := size - 1
+    if endStr != "" {
+        end, err = strconv.ParseInt(endStr, 10, 64)
+        if err != nil || end < start {
+            return byteRange{}, false, nil
+        }
+        if end >= size {
+            end = size - 1
+        }
+    }
+
+    if start >= size {
+        return byteRange{}, true, errUnsatisfiableRange
+    }
+
+    return byteRange{start: start, length: end - start + 1}, true, nil
+}
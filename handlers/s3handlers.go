@@ -1,40 +1,49 @@
 package handlers
 
 import (
+    "bytes"
+    "crypto/md5"
+    "encoding/base64"
     "io"
+    "my-s3-clone/auth"
     "my-s3-clone/storage"
     "my-s3-clone/dto"
+    "my-s3-clone/requestid"
     "net/http"
     "github.com/gorilla/mux"
-    "log"
     "time"
     "encoding/xml"
     "fmt"
-    "os"
     "strconv"
     "errors"
+    "strings"
+    "sync"
 )
 
 // List all buckets
 func HandleListBuckets(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        log.Printf("Received request: %s %s", r.Method, r.URL.Path)
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
 
-        log.Println("Calling storage.ListBuckets to get the list of buckets.")
         buckets := s.ListBuckets()
-
-        if len(buckets) == 0 {
-            log.Println("No buckets found in storage.")
-        } else {
-            log.Printf("Found %d buckets.", len(buckets))
-        }
+        logger.Debug("listing buckets", "count", len(buckets))
 
         var bucketList []dto.Bucket
         for _, bucketName := range buckets {
-            log.Printf("Adding bucket: %s", bucketName)
+            meta, err := s.GetBucketMetadata(bucketName)
+            if errors.Is(err, storage.ErrBucketNotFound) {
+                // Bucket supprimé pendant le listing
+                continue
+            } else if err != nil {
+                logger.Error("could not retrieve bucket metadata", "bucket", bucketName, "error", err)
+                WriteError(w, r, err)
+                return
+            }
             bucketList = append(bucketList, dto.Bucket{
                 Name:         bucketName,
-                CreationDate: time.Now(),
+                CreationDate: meta.CreationDate,
+                BucketRegion: meta.Region,
             })
         }
 
@@ -45,10 +54,8 @@ func HandleListBuckets(s storage.Storage) http.HandlerFunc {
         w.Header().Set("Content-Type", "application/xml")
         w.WriteHeader(http.StatusOK)
 
-        log.Println("Encoding response as XML and sending it.")
         if err := xml.NewEncoder(w).Encode(response); err != nil {
-            http.Error(w, "Erreur lors de l'encodage des buckets en XML", http.StatusInternalServerError)
-            log.Printf("Erreur lors de l'encodage des buckets: %v", err)
+            logger.Error("could not encode bucket list", "error", err)
         }
     }
 }
@@ -56,32 +63,48 @@ func HandleListBuckets(s storage.Storage) http.HandlerFunc {
 // Create a bucket
 func HandleCreateBucket(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        log.Printf("Received request: %s %s", r.Method, r.URL.Path)
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
 
         if r.Method != "PUT" {
-            http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+            WriteError(w, r, storage.ErrMethodNotAllowed)
             return
         }
 
         vars := mux.Vars(r)
         bucketName := vars["bucketName"]
 
+        // Les règles de nommage S3 sont vérifiées avant toute opération sur le stockage
+        if err := storage.ValidateBucketName(bucketName); err != nil {
+            logger.Warn("invalid bucket name", "bucket", bucketName, "error", err)
+            WriteError(w, r, err)
+            return
+        }
+
         // Vérification si le bucket existe déjà
         exists, err := s.CheckBucketExists(bucketName) 
         if err != nil {
-            http.Error(w, "Erreur lors de la vérification du bucket", http.StatusInternalServerError)
+            logger.Error("could not check bucket", "bucket", bucketName, "error", err)
+            WriteError(w, r, err)
             return
         }
 
         if exists {
-            http.Error(w, fmt.Sprintf("Bucket '%s' already exists", bucketName), http.StatusConflict)
+            WriteError(w, r, storage.ErrBucketAlreadyExists)
+            return
+        }
+
+        meta, err := bucketMetadataFromRequest(r)
+        if err != nil {
+            WriteError(w, r, err)
             return
         }
 
         // Création du bucket si il n'existe pas
-        err = s.CreateBucket(bucketName)
+        err = s.CreateBucket(bucketName, meta)
         if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+            logger.Warn("could not create bucket", "bucket", bucketName, "error", err)
+            WriteError(w, r, err)
             return
         }
 
@@ -98,7 +121,7 @@ func HandleCreateBucket(s storage.Storage) http.HandlerFunc {
         w.Header().Set("Location", r.URL.String())
         w.WriteHeader(http.StatusOK)
         if err := xml.NewEncoder(w).Encode(bucketResponse); err != nil {
-            http.Error(w, "Erreur lors de l'encodage XML", http.StatusInternalServerError)
+            logger.Error("could not encode XML response", "error", err)
         }
     }
 }
@@ -106,39 +129,36 @@ func HandleCreateBucket(s storage.Storage) http.HandlerFunc {
 // Get bucket info or location
 func HandleGetBucket(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
         vars := mux.Vars(r)
         bucketName := vars["bucketName"]
 
-        log.Printf("Requête GET pour le bucket: %s", bucketName)
+        logger.Debug("get bucket", "bucket", bucketName)
 
         // Gérer le paramètre de localisation
         locationParam := r.URL.Query().Get("location")
-        log.Printf("Location Param: %s", locationParam)
 
         if locationParam != "" {
-            log.Printf("Demande de localisation pour le bucket: %s", bucketName)
-            w.Header().Set("Content-Type", "application/xml")
-            w.WriteHeader(http.StatusOK)
-            w.Write([]byte(`<LocationConstraint>us-east-1</LocationConstraint>`))
+            logger.Debug("get bucket location", "bucket", bucketName)
+            writeBucketLocation(w, r, s, bucketName)
             return
         }
 
         // Vérifier si le bucket existe
         exists, err := s.CheckBucketExists(bucketName)
         if err != nil {
-            log.Printf("Erreur lors de la vérification du bucket: %v", err)
-            http.Error(w, "Internal server error", http.StatusInternalServerError)
+            logger.Error("could not check bucket", "bucket", bucketName, "error", err)
+            WriteError(w, r, err)
             return
         }
 
         if !exists {
-            log.Printf("Bucket non trouvé: %s", bucketName)
-            http.Error(w, fmt.Sprintf("Bucket '%s' not found", bucketName), http.StatusNotFound)
+            logger.Debug("bucket not found", "bucket", bucketName)
+            WriteError(w, r, storage.ErrNoSuchBucket)
             return
         }
 
         // Si le bucket existe
-        log.Printf("Bucket %s existe et est accessible", bucketName)
         w.WriteHeader(http.StatusOK)
         w.Write([]byte(fmt.Sprintf("Bucket '%s' exists and is accessible.", bucketName)))
     }
@@ -147,45 +167,49 @@ func HandleGetBucket(s storage.Storage) http.HandlerFunc {
 // Add an object
 func HandleAddObject(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        log.Printf("Received request: %s %s", r.Method, r.URL.Path)
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
 
         vars := mux.Vars(r)
         bucketName := vars["bucketName"]
         objectName := vars["objectName"]
 
         if bucketName == "" || objectName == "" {
-            http.Error(w, "Bucket name and object name are required", http.StatusBadRequest)
-            log.Printf("Bucket name or object name missing: bucketName=%s, objectName=%s", bucketName, objectName)
+            WriteError(w, r, storage.ErrInvalidRequest.WithMessage("Bucket name and object name are required."))
+            logger.Warn("bucket name or object name missing", "bucket", bucketName, "key", objectName)
             return
         }
 
-        log.Printf("Uploading object: %s to bucket: %s", objectName, bucketName)
-
-        // Get the total content length from the X-Amz-Decoded-Content-Length header
+        // Streaming uploads announce the decoded size in X-Amz-Decoded-Content-Length,
+        // plain uploads (presigned URLs for instance) rely on Content-Length
         contentLength := r.Header.Get("X-Amz-Decoded-Content-Length")
         if contentLength == "" {
-            log.Printf("Missing X-Amz-Decoded-Content-Length header")
-            http.Error(w, "Missing X-Amz-Decoded-Content-Length header", http.StatusBadRequest)
+            if r.Header.Get("X-Amz-Content-Sha256") == auth.StreamingPayload {
+                logger.Warn("missing X-Amz-Decoded-Content-Length header")
+                WriteError(w, r, storage.ErrMissingContentLength)
+                return
+            }
+            contentLength = strconv.FormatInt(r.ContentLength, 10)
+        }
+
+        meta, err := objectMetadataFromHeaders(r.Header)
+        if err != nil {
+            WriteError(w, r, err)
             return
         }
 
-        log.Printf("Total upload size: %s bytes", contentLength)
+        logger.Debug("uploading object", "bucket", bucketName, "key", objectName, "size", contentLength)
 
-        // Process the uploaded object
-        err := s.AddObject(bucketName, objectName, r.Body, r.Header.Get("X-Amz-Content-Sha256"))
+        // Process the uploaded object, the storage computes the ETag while writing
+        eTag, err := s.AddObject(bucketName, objectName, r.Body, r.Header.Get("X-Amz-Content-Sha256"), meta)
         if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
-            log.Printf("Error uploading object: %v", err)
+            logger.Warn("could not upload object", "bucket", bucketName, "key", objectName, "error", err)
+            WriteError(w, r, err)
             return
         }
 
-        // Generate an ETag for the object
-        eTag := `"1b2cf535f27731c974343645a3985328"`
-
-        // Set the appropriate headers
+        // Set the appropriate headers, x-amz-request-id and x-amz-id-2 are set by the router
         w.Header().Set("ETag", eTag)
-        w.Header().Set("x-amz-id-2", "LriYPLdmOdAiIfgSm/F1YsViT1LW94/xUQxMsF7xiEb1a0wiIOIxl+zbwZ163pt7")
-        w.Header().Set("x-amz-request-id", "0A49CE4060975EAC")
         w.Header().Set("Date", time.Now().Format(http.TimeFormat))
 
         // Send the response
@@ -193,37 +217,40 @@ func HandleAddObject(s storage.Storage) http.HandlerFunc {
         w.Write([]byte{}) // Empty body, as per S3 standard response
 
         // Log response status and body
-        log.Printf("Response status: %d", http.StatusOK)
-        log.Printf("Successfully uploaded object: %s in bucket: %s", objectName, bucketName)
+        logger.Info("object uploaded", "bucket", bucketName, "key", objectName, "etag", eTag)
     }
 }
 
 // Check if an object exists
 func HandleCheckObjectExist(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        log.Printf("Received request: %s %s", r.Method, r.URL.Path)
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
 
         vars := mux.Vars(r)
         bucketName := vars["bucketName"]
         objectName := vars["objectName"]
 
         if bucketName == "" || objectName == "" {
-            http.Error(w, "Bucket name and object name are required", http.StatusBadRequest)
+            WriteError(w, r, storage.ErrInvalidRequest.WithMessage("Bucket name and object name are required."))
             return
         }
 
-        exists, lastModified, size, err := s.CheckObjectExist(bucketName, objectName)
-        if err != nil || !exists {
-            if !exists {
-                http.Error(w, "Object not found", http.StatusNotFound)
-                return
-            }
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+        exists, fileInfo, err := s.CheckObjectExist(bucketName, objectName)
+        if err != nil {
+            WriteError(w, r, err)
+            return
+        }
+        if !exists {
+            WriteError(w, r, storage.ErrObjectNotFound)
             return
         }
 
-        w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
-        w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+        setObjectMetadataHeaders(w.Header(), fileInfo.Metadata())
+        w.Header().Set("Last-Modified", fileInfo.ModTime().Format(http.TimeFormat))
+        w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+        w.Header().Set("ETag", fileInfo.ETag())
+        w.Header().Set("Accept-Ranges", "bytes")
         w.WriteHeader(http.StatusOK)
     }
 }
@@ -231,34 +258,60 @@ func HandleCheckObjectExist(s storage.Storage) http.HandlerFunc {
 // Download an object
 func HandleDownloadObject(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        log.Printf("Received request: %s %s", r.Method, r.URL.Path)
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
 
         vars := mux.Vars(r)
         bucketName := vars["bucketName"]
         objectName := vars["objectName"]
 
-        // Récupérer les données du fichier et ses métadonnées
-        data, fileInfo, err := s.GetObject(bucketName, objectName)
+        // Récupérer le flux du fichier et ses métadonnées
+        reader, fileInfo, err := s.GetObject(bucketName, objectName)
         if err != nil {
-            if os.IsNotExist(err) {
-                http.Error(w, "File not found", http.StatusNotFound)
-                return
-            }
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+            logger.Debug("could not retrieve object", "bucket", bucketName, "key", objectName, "error", err)
+            WriteError(w, r, err)
             return
         }
+        defer reader.Close()
 
-        // Envoyer les métadonnées dans les en-têtes HTTP
-        w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", objectName))
-        w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+        // Envoyer les métadonnées enregistrées à l'envoi dans les en-têtes HTTP
+        setObjectMetadataHeaders(w.Header(), fileInfo.Metadata())
         w.Header().Set("Last-Modified", fileInfo.ModTime().Format(http.TimeFormat))
+        w.Header().Set("ETag", fileInfo.ETag())
+        w.Header().Set("Accept-Ranges", "bytes")
+
+        size := fileInfo.Size()
+        status := http.StatusOK
+        var body io.Reader = reader
+
+        // Gérer les requêtes partielles (Range: bytes=...)
+        if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+            rng, ok, err := parseRange(rangeHeader, size)
+            if err != nil {
+                logger.Debug("unsatisfiable range", "range", rangeHeader, "key", objectName, "size", size)
+                w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+                WriteError(w, r, storage.ErrInvalidRange)
+                return
+            }
+            if ok {
+                if _, err := reader.Seek(rng.start, io.SeekStart); err != nil {
+                    WriteError(w, r, err)
+                    return
+                }
+                w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.start+rng.length-1, size))
+                body = io.LimitReader(reader, rng.length)
+                size = rng.length
+                status = http.StatusPartialContent
+            }
+        }
 
         // Envoyer le contenu du fichier
-        w.Header().Set("Content-Type", "application/octet-stream")
-        w.WriteHeader(http.StatusOK)
+        w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+        w.WriteHeader(status)
 
-        if _, err := w.Write(data); err != nil {
-            http.Error(w, "Failed to write file content", http.StatusInternalServerError)
+        // Le corps est copié par blocs, la mémoire reste constante quelle que soit la taille de l'objet
+        if _, err := io.Copy(w, body); err != nil {
+            logger.Warn("could not stream object", "bucket", bucketName, "key", objectName, "error", err)
         }
     }
 }
@@ -266,12 +319,14 @@ func HandleDownloadObject(s storage.Storage) http.HandlerFunc {
 // List objects in a bucket
 func HandleListObjects(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
         vars := mux.Vars(r)
         bucketName := vars["bucketName"]
 
         queryParams := r.URL.Query()
         prefix := queryParams.Get("prefix")
         marker := queryParams.Get("marker")
+        delimiter := queryParams.Get("delimiter")
         maxKeys := queryParams.Get("max-keys")
 
         if maxKeys == "" {
@@ -279,232 +334,394 @@ func HandleListObjects(s storage.Storage) http.HandlerFunc {
         }
 
         maxKeysInt, err := strconv.Atoi(maxKeys)
-        if err != nil {
-            http.Error(w, "Invalid max-keys value", http.StatusBadRequest)
+        if err != nil || maxKeysInt < 0 {
+            WriteError(w, r, storage.ErrInvalidArgument.WithMessage("Provided max-keys not an integer or within integer range."))
+            return
+        }
+        // Comme S3, une page ne contient jamais plus de 1000 entrées
+        if maxKeysInt > 1000 {
+            maxKeysInt = 1000
+        }
+
+        // ListObjectsV2 (GET /bucket?list-type=2), used by recent SDKs
+        if queryParams.Get("list-type") == "2" {
+            listObjectsV2(w, r, s, bucketName, prefix, delimiter, maxKeysInt)
             return
         }
 
-        objects, err := s.ListObjects(bucketName, prefix, marker, maxKeysInt)
+        objects, err := s.ListObjects(bucketName, prefix, marker, delimiter, maxKeysInt)
         if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+            logger.Warn("could not list objects", "bucket", bucketName, "error", err)
+            WriteError(w, r, err)
             return
         }
 
         w.Header().Set("Content-Type", "application/xml")
         w.WriteHeader(http.StatusOK)
         if err := xml.NewEncoder(w).Encode(objects); err != nil {
-            http.Error(w, "Erreur lors de l'encodage XML", http.StatusInternalServerError)
+            logger.Error("could not encode XML response", "error", err)
+        }
+    }
+}
+
+// List objects with the V2 API: pagination relies on an opaque continuation token
+// wrapping the last returned key, on top of the sorted listing of the storage
+func listObjectsV2(w http.ResponseWriter, r *http.Request, s storage.Storage, bucketName, prefix, delimiter string, maxKeys int) {
+    logger := requestid.Logger(r.Context())
+    queryParams := r.URL.Query()
+    continuationToken := queryParams.Get("continuation-token")
+    startAfter := queryParams.Get("start-after")
+    fetchOwner := queryParams.Get("fetch-owner") == "true"
+
+    // Le jeton de continuation prime sur start-after
+    marker := startAfter
+    if continuationToken != "" {
+        decoded, err := decodeContinuationToken(continuationToken)
+        if err != nil {
+            logger.Debug("invalid continuation token", "token", continuationToken, "error", err)
+            WriteError(w, r, storage.ErrInvalidArgument.WithMessage("The continuation token provided is incorrect."))
+            return
+        }
+        marker = decoded
+    }
+
+    objects, err := s.ListObjects(bucketName, prefix, marker, delimiter, maxKeys)
+    if err != nil {
+        logger.Warn("could not list objects", "bucket", bucketName, "error", err)
+        WriteError(w, r, err)
+        return
+    }
+
+    response := dto.ListObjectsV2Response{
+        Xmlns:             "http://s3.amazonaws.com/doc/2006-03-01/",
+        Name:              bucketName,
+        Prefix:            prefix,
+        Delimiter:         delimiter,
+        MaxKeys:           maxKeys,
+        KeyCount:          len(objects.Contents) + len(objects.CommonPrefixes),
+        IsTruncated:       objects.IsTruncated,
+        ContinuationToken: continuationToken,
+        StartAfter:        startAfter,
+        Contents:          objects.Contents,
+        CommonPrefixes:    objects.CommonPrefixes,
+    }
+
+    if fetchOwner {
+        for i := range response.Contents {
+            response.Contents[i].Owner = &defaultOwner
         }
     }
+
+    if objects.IsTruncated {
+        response.NextContinuationToken = encodeContinuationToken(lastListedEntry(objects))
+    }
+
+    w.Header().Set("Content-Type", "application/xml")
+    w.WriteHeader(http.StatusOK)
+    if err := xml.NewEncoder(w).Encode(response); err != nil {
+        logger.Error("could not encode ListObjectsV2 response", "error", err)
+    }
+}
+
+// Owner reported for every object, the server has no per-user ownership
+var defaultOwner = dto.Owner{
+    ID:          "02d6176db174dc93cb1b899f7c6078f08654445fe8cf1b6ce98d8855f66bdbf4",
+    DisplayName: "my-s3-clone",
+}
+
+// Last key or common prefix of a listing page, whichever sorts last
+func lastListedEntry(objects dto.ListObjectsResponse) string {
+    last := ""
+    if n := len(objects.Contents); n > 0 {
+        last = objects.Contents[n-1].Key
+    }
+    if n := len(objects.CommonPrefixes); n > 0 && objects.CommonPrefixes[n-1].Prefix > last {
+        last = objects.CommonPrefixes[n-1].Prefix
+    }
+    return last
+}
+
+func encodeContinuationToken(key string) string {
+    return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeContinuationToken(token string) (string, error) {
+    decoded, err := base64.RawURLEncoding.DecodeString(token)
+    if err != nil {
+        return "", err
+    }
+    return string(decoded), nil
 }
 
 // Delete a bucket
 func HandleDeleteBucket(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        log.Printf("Received request: %s %s", r.Method, r.URL.Path)
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
         vars := mux.Vars(r)
         bucketName := vars["bucketName"]
         
         if bucketName == "" {
-            http.Error(w, "Bucket name is required", http.StatusBadRequest)
+            WriteError(w, r, storage.ErrInvalidRequest.WithMessage("Bucket name is required."))
             return
         }
 
         // Tenter de supprimer le bucket
         err := s.DeleteBucket(bucketName)
         if err != nil {
-            // Si l'erreur indique que le bucket n'existe pas, renvoyer un code 404
-            if os.IsNotExist(err) {
-                log.Printf("Bucket %s does not exist", bucketName)
-                http.Error(w, fmt.Sprintf("Bucket %s does not exist", bucketName), http.StatusNotFound)
-                return
-            }
-            // Pour toute autre erreur, renvoyer un code 500
-            log.Printf("Error deleting bucket %s: %v", bucketName, err)
-            http.Error(w, "Failed to delete bucket", http.StatusInternalServerError)
+            // Les erreurs du stockage (bucket absent...) portent leur code S3, les autres deviennent des 500
+            logger.Warn("could not delete bucket", "bucket", bucketName, "error", err)
+            WriteError(w, r, err)
             return
         }
 
         // Répondre avec succès si le bucket est supprimé
-        log.Printf("Bucket %s deleted successfully", bucketName)
+        logger.Info("bucket deleted", "bucket", bucketName)
+        w.WriteHeader(http.StatusNoContent)
+    }
+}
+
+
+// Delete a single object (DELETE /bucket/key), missing keys are reported as deleted like S3 does
+func HandleDeleteSingleObject(s storage.Storage) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
+        logger.Debug("received request", "method", r.Method, "path", r.URL.Path)
+
+        vars := mux.Vars(r)
+        if err := deleteObject(r, s, vars["bucketName"], vars["objectName"]); err != nil {
+            WriteError(w, r, err)
+            return
+        }
         w.WriteHeader(http.StatusNoContent)
     }
 }
 
+// Suppression d'un objet commune aux suppressions unitaire et groupée :
+// comme sur S3, supprimer une clé absente réussit, seules les autres erreurs sont remontées
+func deleteObject(r *http.Request, s storage.Storage, bucketName, objectName string) error {
+    logger := requestid.Logger(r.Context())
 
-// Batch delete objects
+    err := s.DeleteObject(bucketName, objectName)
+    if errors.Is(err, storage.ErrObjectNotFound) {
+        logger.Debug("object to delete not found", "bucket", bucketName, "key", objectName)
+        return nil
+    }
+    if err != nil {
+        logger.Warn("could not delete object", "bucket", bucketName, "key", objectName, "error", err)
+        return err
+    }
+    logger.Info("object deleted", "bucket", bucketName, "key", objectName)
+    return nil
+}
+
+// Nombre maximal de clés par requête de suppression groupée, comme sur S3
+const maxDeleteObjects = 1000
+
+// Taille maximale du corps d'une requête de suppression groupée (1000 clés de 1024 octets et le balisage)
+const maxDeleteRequestSize = 2 << 20
+
+// Nombre de suppressions menées en parallèle dans une suppression groupée
+const deleteWorkers = 16
+
+// Batch delete objects (POST /bucket?delete)
 func HandleDeleteObject(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
+        logger := requestid.Logger(r.Context())
         if r.Method != http.MethodPost {
-            http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+            WriteError(w, r, storage.ErrMethodNotAllowed)
             return
         }
-        log.Printf("Received POST ?delete request for batch deletion: %s %s", r.Method, r.URL.Path)
+        logger.Debug("received batch delete request", "path", r.URL.Path)
 
         vars := mux.Vars(r)
         bucketName := vars["bucketName"]
 
-        body, err := io.ReadAll(r.Body)
+        body, err := io.ReadAll(io.LimitReader(r.Body, maxDeleteRequestSize+1))
         if err != nil {
-            http.Error(w, "Error reading request body", http.StatusInternalServerError)
-            log.Printf("Error reading request body: %v", err)
+            logger.Warn("could not read request body", "error", err)
+            WriteError(w, r, err)
+            return
+        }
+        if len(body) > maxDeleteRequestSize {
+            WriteError(w, r, storage.ErrMalformedXML)
+            return
+        }
+        if err := checkContentMD5(r.Header.Get("Content-MD5"), body); err != nil {
+            WriteError(w, r, err)
             return
         }
-        log.Printf("Request body: %s", string(body))
 
         var deleteReq dto.DeleteObjectRequest
-        err = xml.Unmarshal(body, &deleteReq)
+        if err := xml.Unmarshal(body, &deleteReq); err != nil {
+            logger.Debug("could not parse batch delete body", "error", err)
+            WriteError(w, r, storage.ErrMalformedXML)
+            return
+        }
+        if len(deleteReq.Objects) == 0 || len(deleteReq.Objects) > maxDeleteObjects {
+            WriteError(w, r, storage.ErrMalformedXML)
+            return
+        }
+
+        // Un bucket absent fait échouer toute la requête, pas chaque clé
+        exists, err := s.CheckBucketExists(bucketName)
         if err != nil {
-            http.Error(w, "Error parsing XML", http.StatusBadRequest)
-            log.Printf("Error parsing XML: %v", err)
+            WriteError(w, r, err)
+            return
+        }
+        if !exists {
+            WriteError(w, r, storage.ErrBucketNotFound)
             return
         }
 
-        var deletedObjects []dto.Deleted
-        for _, objectToDelete := range deleteReq.Objects {
-            log.Printf("Attempting to delete object: %s", objectToDelete.Key)
-            err := s.DeleteObject(bucketName, objectToDelete.Key)
-            if err != nil {
-                if errors.Is(err, os.ErrNotExist) { // Vérifie si l'erreur correspond à l'objet non trouvé
-                    http.Error(w, "Object not found", http.StatusNotFound)
-                    log.Printf("Object not found: %s", objectToDelete.Key)
-                    continue 
+        errs := deleteObjects(r, s, bucketName, deleteReq.Objects)
+
+        response := dto.DeleteResult{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/"}
+        for i, object := range deleteReq.Objects {
+            if errs[i] == nil {
+                if !deleteReq.Quiet {
+                    response.DeletedResult = append(response.DeletedResult, dto.Deleted{Key: object.Key})
                 }
-                http.Error(w, "Error deleting object", http.StatusInternalServerError)
-                log.Printf("Error deleting object %s: %v", objectToDelete.Key, err)
-                return
+                continue
             }
-            log.Printf("Successfully deleted object: %s", objectToDelete.Key)
-
-            deletedObjects = append(deletedObjects, dto.Deleted{Key: objectToDelete.Key})
+            apiErr := toAPIError(errs[i])
+            response.Errors = append(response.Errors, dto.DeleteError{Key: object.Key, Code: apiErr.Code, Message: apiErr.Message})
         }
 
-        deleteResult := dto.DeleteResult{
-            DeletedResult: deletedObjects,
-        }
+        writeXML(w, r, http.StatusOK, response)
+    }
+}
 
-        response, err := xml.Marshal(deleteResult)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
-        }
+// Suppression des objets en parallèle, l'erreur de chaque clé est rangée à son index
+func deleteObjects(r *http.Request, s storage.Storage, bucketName string, objects []dto.ObjectToDelete) []error {
+    errs := make([]error, len(objects))
+    indexes := make(chan int)
+
+    var wg sync.WaitGroup
+    for n := 0; n < deleteWorkers && n < len(objects); n++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range indexes {
+                errs[i] = deleteObject(r, s, bucketName, objects[i].Key)
+            }
+        }()
+    }
+    for i := range objects {
+        indexes <- i
+    }
+    close(indexes)
+    wg.Wait()
 
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
+    return errs
+}
 
-                // Log response status and body
-        log.Printf("Response status: %d", http.StatusOK)
-        log.Printf("Response body: %s", string(response))
+// Vérification de l'en-tête Content-MD5 (MD5 du corps encodé en base64) lorsqu'il est fourni
+func checkContentMD5(header string, body []byte) error {
+    if header == "" {
+        return nil
+    }
+    expected, err := base64.StdEncoding.DecodeString(header)
+    if err != nil || len(expected) != md5.Size {
+        return storage.ErrInvalidDigest
+    }
+    sum := md5.Sum(body)
+    if !bytes.Equal(sum[:], expected) {
+        return storage.ErrBadDigest
     }
+    return nil
 }
 
+// Region of a bucket (GET /bucket?location), as given in CreateBucketConfiguration when it was created
 func HandleBucketLocation(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
-            // La chaîne représentant la date
-    dateString := "2024-09-16T10:12:24Z"
+        writeBucketLocation(w, r, s, mux.Vars(r)["bucketName"])
+    }
+}
 
-    // Convertir la chaîne en time.Time
-    creationDate, err := time.Parse(time.RFC3339, dateString)
+func writeBucketLocation(w http.ResponseWriter, r *http.Request, s storage.Storage, bucketName string) {
+    meta, err := s.GetBucketMetadata(bucketName)
     if err != nil {
-        fmt.Printf("Error parsing date: %v\n", err)
+        requestid.Logger(r.Context()).Warn("could not retrieve bucket metadata", "bucket", bucketName, "error", err)
+        WriteError(w, r, err)
         return
     }
-        bucket := dto.Bucket{
-            Name:         bucketName,
-            CreationDate: creationDate,
-            LocationConstraint : "us-east-1",
-        }
-
-        response, err := xml.Marshal(bucket)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
-        }
-
-        
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-            
-    }
+    writeXML(w, r, http.StatusOK, dto.LocationConstraint{Region: meta.Region})
 }
 
+// Object lock configuration of a bucket (GET /bucket?object-lock). Object lock is not supported
+// (see bucketMetadataFromRequest), so no bucket ever has one.
 func HandleBucketLockConfig(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
+        bucketName := mux.Vars(r)["bucketName"]
 
-        dateString := "2024-09-16T10:12:24Z"
-
-        // Convertir la chaîne en time.Time
-        creationDate, err := time.Parse(time.RFC3339, dateString)
-        if err != nil {
-            fmt.Printf("Error parsing date: %v\n", err)
+        if _, err := s.GetBucketMetadata(bucketName); err != nil {
+            requestid.Logger(r.Context()).Warn("could not retrieve bucket metadata", "bucket", bucketName, "error", err)
+            WriteError(w, r, err)
             return
         }
+        WriteError(w, r, storage.ErrObjectLockConfigurationNotFound)
+    }
+}
 
-        bucket := dto.Bucket{
-            Name:         bucketName,
-            CreationDate: creationDate,
-            ObjectLockConfig : "true",
-        }
-    
+// Versioning state of a bucket (GET /bucket?versioning). Versioning is not supported: objects are
+// overwritten and deleted in place, so the state is always the one of a bucket never versioned.
+func HandleBucketVersioning(s storage.Storage) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        bucketName := mux.Vars(r)["bucketName"]
 
-        response, err := xml.Marshal(bucket)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
+        if _, err := s.GetBucketMetadata(bucketName); err != nil {
+            requestid.Logger(r.Context()).Warn("could not retrieve bucket metadata", "bucket", bucketName, "error", err)
+            WriteError(w, r, err)
             return
         }
 
-        
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-            
-            
+        writeXML(w, r, http.StatusOK, dto.VersioningConfiguration{
+            Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+        })
     }
 }
 
+// Taille maximale du corps CreateBucketConfiguration
+const maxCreateBucketConfigurationSize = 64 << 10
 
-func HandleBucketDelimiter(s storage.Storage) http.HandlerFunc {
-    return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
-
-        dateString := "2024-09-16T10:12:24Z"
+// Métadonnées d'un bucket d'après la requête de création : région (CreateBucketConfiguration) et access key
+// à l'origine de la requête. Le verrouillage d'objets (x-amz-bucket-object-lock-enabled) est refusé tant que
+// la rétention et le versioning ne sont pas appliqués : le client croirait ses objets protégés.
+func bucketMetadataFromRequest(r *http.Request) (dto.BucketMetadata, error) {
+    var meta dto.BucketMetadata
 
-        // Convertir la chaîne en time.Time
-        creationDate, err := time.Parse(time.RFC3339, dateString)
-        if err != nil {
-            fmt.Printf("Error parsing date: %v\n", err)
-            return
-        }
+    if result := auth.FromContext(r.Context()); result != nil {
+        meta.Owner = result.AccessKey
+    }
 
-        bucket := dto.Bucket{
-            Name:         bucketName,
-            CreationDate: creationDate,
-            ObjectDelimiter : "true",
-        }
-    
+    switch strings.ToLower(r.Header.Get("x-amz-bucket-object-lock-enabled")) {
+    case "":
+    case "true":
+        return meta, storage.ErrNotImplemented.WithMessage("Object Lock is not supported by this server.")
+    case "false":
+    default:
+        return meta, storage.ErrInvalidArgument.WithMessage("x-amz-bucket-object-lock-enabled must be true or false.")
+    }
 
-        response, err := xml.Marshal(bucket)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
-        }
+    if r.Body == nil {
+        return meta, nil
+    }
+    body, err := io.ReadAll(io.LimitReader(r.Body, maxCreateBucketConfigurationSize+1))
+    if err != nil {
+        return meta, err
+    }
+    if len(bytes.TrimSpace(body)) == 0 {
+        return meta, nil
+    }
+    if len(body) > maxCreateBucketConfigurationSize {
+        return meta, storage.ErrMalformedXML
+    }
 
-        
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-            
-            
+    var config dto.CreateBucketConfiguration
+    if err := xml.Unmarshal(body, &config); err != nil {
+        return meta, storage.ErrMalformedXML
     }
-}
\ No newline at end of file
+    meta.Region = strings.TrimSpace(config.LocationConstraint)
+    return meta, nil
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+    "fmt"
+    "io"
+    "log/slog"
+    "os"
+    "strings"
+)
+
+// Setup installe le logger slog par défaut à partir de LOG_LEVEL (debug, info, warn, error ; info par défaut)
+// et LOG_FORMAT (text ou json ; text par défaut). Les logs sont écrits sur la sortie d'erreur.
+func Setup() error {
+    handler, err := NewHandler(os.Stderr, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+    if err != nil {
+        return err
+    }
+    slog.SetDefault(slog.New(handler))
+    return nil
+}
+
+// NewHandler construit un handler slog écrivant dans w au niveau et au format donnés
+func NewHandler(w io.Writer, level, format string) (slog.Handler, error) {
+    lvl, err := ParseLevel(level)
+    if err != nil {
+        return nil, err
+    }
+    opts := &slog.HandlerOptions{Level: lvl}
+
+    switch strings.ToLower(strings.TrimSpace(format)) {
+    case "", "text":
+        return slog.NewTextHandler(w, opts), nil
+    case "json":
+        return slog.NewJSONHandler(w, opts), nil
+    default:
+        return nil, fmt.Errorf("invalid LOG_FORMAT %q: expected text or json", format)
+    }
+}
+
+// ParseLevel convertit un nom de niveau (insensible à la casse) en slog.Level, info si vide
+func ParseLevel(level string) (slog.Level, error) {
+    switch strings.ToLower(strings.TrimSpace(level)) {
+    case "debug":
+        return slog.LevelDebug, nil
+    case "", "info":
+        return slog.LevelInfo, nil
+    case "warn", "warning":
+        return slog.LevelWarn, nil
+    case "error":
+        return slog.LevelError, nil
+    default:
+        return 0, fmt.Errorf("invalid LOG_LEVEL %q: expected debug, info, warn or error", level)
+    }
+}
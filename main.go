@@ -1,21 +1,31 @@
 package main
 
 import (
-    "log"
+    "log/slog"
     "net/http"
     "os"
+    "my-s3-clone/logging"
     "my-s3-clone/router"
 )
 
 func main() {
+    if err := logging.Setup(); err != nil {
+        slog.Error("invalid logging configuration", "error", err)
+        os.Exit(1)
+    }
+
     if _, err := os.Stat("./buckets"); os.IsNotExist(err) {
-        log.Printf("Le répertoire 'buckets' n'existe pas. Création...")
+        slog.Info("creating buckets directory", "path", "./buckets")
         if err := os.Mkdir("./buckets", os.ModePerm); err != nil {
-            log.Fatalf("Erreur lors de la création du répertoire 'buckets': %v", err)
+            slog.Error("could not create buckets directory", "path", "./buckets", "error", err)
+            os.Exit(1)
         }
     }
 
-    r := router.SetupRouter() 
-    log.Println("Serving on :9090")
-    log.Fatal(http.ListenAndServe(":9090", r))
+    r := router.SetupRouter()
+    slog.Info("serving", "addr", ":9090")
+    if err := http.ListenAndServe(":9090", r); err != nil {
+        slog.Error("server stopped", "error", err)
+        os.Exit(1)
+    }
 }
@@ -0,0 +1,227 @@
+package metrics
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// Bornes des histogrammes de latence, en secondes (valeurs par défaut du client Prometheus)
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Une série est identifiée par les valeurs de ses labels, jointes par un octet qui ne peut pas
+// apparaître dans une chaîne UTF-8 valide
+const labelSeparator = "\xff"
+
+// family regroupe les séries d'une métrique et sait les écrire au format texte de Prometheus
+type family interface {
+    write(w io.Writer) error
+}
+
+// CounterVec est un compteur décliné par valeurs de labels
+type CounterVec struct {
+    name, help string
+    labels     []string
+
+    mu     sync.Mutex
+    values map[string]float64
+}
+
+// Add incrémente de v la série correspondant aux valeurs de labels données
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+    key := seriesKey(c.labels, labelValues)
+    c.mu.Lock()
+    c.values[key] += v
+    c.mu.Unlock()
+}
+
+// Inc incrémente de 1 la série correspondant aux valeurs de labels données
+func (c *CounterVec) Inc(labelValues ...string) {
+    c.Add(1, labelValues...)
+}
+
+// Value retourne la valeur courante d'une série (0 si elle n'existe pas)
+func (c *CounterVec) Value(labelValues ...string) float64 {
+    key := seriesKey(c.labels, labelValues)
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.values[key]
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+        return err
+    }
+    for _, key := range sortedKeys(c.values) {
+        if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, key, "", ""), formatValue(c.values[key])); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Gauge est une valeur qui peut monter et descendre (requêtes en cours par exemple)
+type Gauge struct {
+    name, help string
+
+    mu    sync.Mutex
+    value float64
+}
+
+// Add ajoute v (éventuellement négatif) à la jauge
+func (g *Gauge) Add(v float64) {
+    g.mu.Lock()
+    g.value += v
+    g.mu.Unlock()
+}
+
+// Value retourne la valeur courante de la jauge
+func (g *Gauge) Value() float64 {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    return g.value
+}
+
+func (g *Gauge) write(w io.Writer) error {
+    _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, formatValue(g.Value()))
+    return err
+}
+
+// HistogramVec répartit des observations (des durées) dans des intervalles cumulatifs, par valeurs de labels
+type HistogramVec struct {
+    name, help string
+    labels     []string
+    buckets    []float64
+
+    mu     sync.Mutex
+    series map[string]*histogram
+}
+
+type histogram struct {
+    counts []uint64 // une entrée par borne, non cumulée
+    count  uint64
+    sum    float64
+}
+
+// Observe enregistre la valeur v dans la série correspondant aux valeurs de labels données
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+    key := seriesKey(h.labels, labelValues)
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    s, ok := h.series[key]
+    if !ok {
+        s = &histogram{counts: make([]uint64, len(h.buckets))}
+        h.series[key] = s
+    }
+    if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+        s.counts[i]++
+    }
+    s.count++
+    s.sum += v
+}
+
+// Count retourne le nombre d'observations d'une série
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+    key := seriesKey(h.labels, labelValues)
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if s, ok := h.series[key]; ok {
+        return s.count
+    }
+    return 0
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+        return err
+    }
+    keys := make([]string, 0, len(h.series))
+    for key := range h.series {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+
+    for _, key := range keys {
+        s := h.series[key]
+        cumulative := uint64(0)
+        for i, bound := range h.buckets {
+            cumulative += s.counts[i]
+            if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", formatValue(bound)), cumulative); err != nil {
+                return err
+            }
+        }
+        if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+            h.name, formatLabels(h.labels, key, "le", "+Inf"), s.count,
+            h.name, formatLabels(h.labels, key, "", ""), formatValue(s.sum),
+            h.name, formatLabels(h.labels, key, "", ""), s.count); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func seriesKey(labels, labelValues []string) string {
+    if len(labels) != len(labelValues) {
+        panic(fmt.Sprintf("metrics: expected %d label values, got %d", len(labels), len(labelValues)))
+    }
+    return strings.Join(labelValues, labelSeparator)
+}
+
+func newCounterVec(name, help string, labels ...string) *CounterVec {
+    return &CounterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+    return &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+func sortedKeys(values map[string]float64) []string {
+    keys := make([]string, 0, len(values))
+    for key := range values {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// Rendu des labels d'une série ({operation="GetObject",status="200"}), avec un label supplémentaire optionnel (le)
+func formatLabels(labels []string, key, extraName, extraValue string) string {
+    pairs := make([]string, 0, len(labels)+1)
+    if len(labels) > 0 {
+        for i, value := range strings.Split(key, labelSeparator) {
+            pairs = append(pairs, labels[i]+"="+quoteLabelValue(value))
+        }
+    }
+    if extraName != "" {
+        pairs = append(pairs, extraName+"="+quoteLabelValue(extraValue))
+    }
+    if len(pairs) == 0 {
+        return ""
+    }
+    return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Seuls l'antislash, le guillemet et le saut de ligne sont échappés dans une valeur de label
+func quoteLabelValue(value string) string {
+    return `"` + labelValueEscaper.Replace(value) + `"`
+}
+
+func formatValue(v float64) string {
+    if math.IsInf(v, 1) {
+        return "+Inf"
+    }
+    return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+    "io"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gorilla/mux"
+)
+
+// Opération attribuée aux requêtes dont la route n'a pas de nom
+const unknownOperation = "Unknown"
+
+// Middleware mesure chaque requête routée. L'opération S3 est le nom de la route (voir router.SetupRouterWithStorage).
+func (reg *Registry) Middleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        operation := unknownOperation
+        if route := mux.CurrentRoute(r); route != nil && route.GetName() != "" {
+            operation = route.GetName()
+        }
+
+        reg.InFlight.Add(1)
+        defer reg.InFlight.Add(-1)
+
+        start := time.Now()
+        body := &countingReadCloser{ReadCloser: r.Body}
+        if r.Body != nil && r.Body != http.NoBody {
+            r.Body = body
+        }
+        mrw := &metricsResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+        next.ServeHTTP(mrw, r)
+
+        status := strconv.Itoa(mrw.statusCode)
+        reg.Requests.Inc(operation, status)
+        reg.RequestDuration.Observe(time.Since(start).Seconds(), operation, status)
+        reg.ReceivedBytes.Add(float64(body.n), operation)
+        reg.SentBytes.Add(float64(mrw.bytes), operation)
+    })
+}
+
+type countingReadCloser struct {
+    io.ReadCloser
+    n int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+    n, err := c.ReadCloser.Read(p)
+    c.n += int64(n)
+    return n, err
+}
+
+type metricsResponseWriter struct {
+    http.ResponseWriter
+    statusCode  int
+    wroteHeader bool
+    bytes       int64
+}
+
+func (mrw *metricsResponseWriter) WriteHeader(code int) {
+    if !mrw.wroteHeader {
+        mrw.wroteHeader = true
+        mrw.statusCode = code
+    }
+    mrw.ResponseWriter.WriteHeader(code)
+}
+
+func (mrw *metricsResponseWriter) Write(b []byte) (int, error) {
+    mrw.wroteHeader = true
+    n, err := mrw.ResponseWriter.Write(b)
+    mrw.bytes += int64(n)
+    return n, err
+}
+
+// Unwrap donne accès au ResponseWriter d'origine (http.ResponseController)
+func (mrw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+    return mrw.ResponseWriter
+}
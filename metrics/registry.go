@@ -0,0 +1,158 @@
+package metrics
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "log/slog"
+    "my-s3-clone/dto"
+    "my-s3-clone/storage"
+    "net/http"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Registry regroupe les métriques d'une instance du serveur et les expose au format texte de Prometheus
+type Registry struct {
+    Requests        *CounterVec   // requêtes traitées, par opération S3 et statut HTTP
+    RequestDuration *HistogramVec // latence des requêtes, par opération S3 et statut HTTP
+    ReceivedBytes   *CounterVec   // octets de corps de requête lus, par opération S3
+    SentBytes       *CounterVec   // octets de corps de réponse écrits, par opération S3
+    InFlight        *Gauge        // requêtes en cours de traitement
+    StorageCalls    *CounterVec   // appels au stockage, par méthode
+    StorageErrors   *CounterVec   // erreurs renvoyées par le stockage, par méthode et code S3
+
+    families []family
+}
+
+// NewRegistry crée un registre vide, sans statistiques par bucket (voir WatchBuckets)
+func NewRegistry() *Registry {
+    reg := &Registry{
+        Requests: newCounterVec("s3_requests_total",
+            "Total number of S3 requests by operation and HTTP status.", "operation", "status"),
+        RequestDuration: newHistogramVec("s3_request_duration_seconds",
+            "S3 request latency in seconds by operation and HTTP status.", DefaultBuckets, "operation", "status"),
+        ReceivedBytes: newCounterVec("s3_received_bytes_total",
+            "Total number of request body bytes received by operation.", "operation"),
+        SentBytes: newCounterVec("s3_sent_bytes_total",
+            "Total number of response body bytes sent by operation.", "operation"),
+        InFlight: &Gauge{name: "s3_requests_in_flight",
+            help: "Number of S3 requests currently being served."},
+        StorageCalls: newCounterVec("s3_storage_operations_total",
+            "Total number of storage backend calls by method.", "method"),
+        StorageErrors: newCounterVec("s3_storage_errors_total",
+            "Total number of storage backend errors by method and S3 error code.", "method", "code"),
+    }
+    reg.families = []family{
+        reg.Requests, reg.RequestDuration, reg.ReceivedBytes, reg.SentBytes,
+        reg.InFlight, reg.StorageCalls, reg.StorageErrors,
+    }
+    return reg
+}
+
+// WatchBuckets ajoute le nombre d'objets et le volume de chaque bucket de s, recalculés au plus une fois
+// par bucketUsageTTL quel que soit le nombre de collectes.
+// s doit être le stockage d'origine : la collecte ne doit pas compter dans les appels instrumentés.
+func (reg *Registry) WatchBuckets(s storage.Storage) {
+    reg.families = append(reg.families, &bucketCollector{storage: s, ttl: bucketUsageTTL})
+}
+
+// Handler sert les métriques (GET /metrics)
+func (reg *Registry) Handler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+        w.WriteHeader(http.StatusOK)
+        if err := reg.Write(w); err != nil {
+            slog.Warn("could not write metrics", "error", err)
+        }
+    })
+}
+
+// Write écrit toutes les métriques du registre dans w
+func (reg *Registry) Write(w io.Writer) error {
+    buf := bufio.NewWriter(w)
+    for _, f := range reg.families {
+        if err := f.write(buf); err != nil {
+            return err
+        }
+    }
+    return buf.Flush()
+}
+
+// LoadToken lit depuis METRICS_TOKEN le bearer token exigé pour lire /metrics.
+// Sans cette variable, /metrics refuse toutes les requêtes.
+func LoadToken() string {
+    token := strings.TrimSpace(os.Getenv("METRICS_TOKEN"))
+    if token == "" {
+        slog.Warn("METRICS_TOKEN is not set, /metrics is disabled")
+    }
+    return token
+}
+
+// Durée pendant laquelle le volume des buckets est servi sans nouveau parcours du stockage
+const bucketUsageTTL = time.Minute
+
+// bucketCollector parcourt les buckets (un os.Stat par objet, voir Storage.BucketUsage) : les chiffres
+// sont exacts même pour les objets écrits avant le démarrage du processus. Le parcours étant proportionnel
+// au stockage, son résultat est conservé ttl, et les collectes simultanées attendent le même parcours.
+type bucketCollector struct {
+    storage storage.Storage
+    ttl     time.Duration
+
+    mu          sync.Mutex
+    usage       map[string]dto.BucketUsage
+    collectedAt time.Time
+}
+
+func (c *bucketCollector) collect() map[string]dto.BucketUsage {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if c.usage != nil && time.Since(c.collectedAt) < c.ttl {
+        return c.usage
+    }
+    usage := make(map[string]dto.BucketUsage)
+    for _, bucketName := range c.storage.ListBuckets() {
+        u, err := c.storage.BucketUsage(bucketName)
+        if err != nil {
+            // Le bucket a pu être supprimé entre le listage des buckets et celui de ses objets
+            slog.Debug("could not collect bucket usage", "bucket", bucketName, "error", err)
+            continue
+        }
+        usage[bucketName] = u
+    }
+    c.usage = usage
+    c.collectedAt = time.Now()
+    return usage
+}
+
+func (c *bucketCollector) write(w io.Writer) error {
+    usage := c.collect()
+
+    names := make([]string, 0, len(usage))
+    for name := range usage {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    if _, err := fmt.Fprint(w, "# HELP s3_bucket_objects Number of objects stored in each bucket.\n# TYPE s3_bucket_objects gauge\n"); err != nil {
+        return err
+    }
+    for _, name := range names {
+        if _, err := fmt.Fprintf(w, "s3_bucket_objects{bucket=%s} %d\n", quoteLabelValue(name), usage[name].Objects); err != nil {
+            return err
+        }
+    }
+    if _, err := fmt.Fprint(w, "# HELP s3_bucket_bytes Total size in bytes of the objects stored in each bucket.\n# TYPE s3_bucket_bytes gauge\n"); err != nil {
+        return err
+    }
+    for _, name := range names {
+        if _, err := fmt.Fprintf(w, "s3_bucket_bytes{bucket=%s} %d\n", quoteLabelValue(name), usage[name].Bytes); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+    "errors"
+    "io"
+    "my-s3-clone/dto"
+    "my-s3-clone/storage"
+)
+
+// instrumentedStorage compte les appels et les erreurs d'un storage.Storage
+type instrumentedStorage struct {
+    storage.Storage
+    reg *Registry
+}
+
+// InstrumentStorage enveloppe s pour compter ses appels et ses erreurs dans reg
+func (reg *Registry) InstrumentStorage(s storage.Storage) storage.Storage {
+    return &instrumentedStorage{Storage: s, reg: reg}
+}
+
+// observe enregistre un appel à method et, le cas échéant, son erreur sous son code S3
+func (s *instrumentedStorage) observe(method string, err error) {
+    s.reg.StorageCalls.Inc(method)
+    if err == nil {
+        return
+    }
+    code := storage.ErrInternalError.Code
+    var apiErr *storage.APIError
+    if errors.As(err, &apiErr) {
+        code = apiErr.Code
+    }
+    s.reg.StorageErrors.Inc(method, code)
+}
+
+func (s *instrumentedStorage) AddObject(bucketName, objectName string, data io.Reader, contentSha256 string, meta dto.ObjectMetadata) (string, error) {
+    etag, err := s.Storage.AddObject(bucketName, objectName, data, contentSha256, meta)
+    s.observe("AddObject", err)
+    return etag, err
+}
+
+func (s *instrumentedStorage) CopyObject(srcBucket, srcObject, dstBucket, dstObject string, meta *dto.ObjectMetadata) (dto.FileInfo, error) {
+    info, err := s.Storage.CopyObject(srcBucket, srcObject, dstBucket, dstObject, meta)
+    s.observe("CopyObject", err)
+    return info, err
+}
+
+func (s *instrumentedStorage) DeleteObject(bucketName, objectName string) error {
+    err := s.Storage.DeleteObject(bucketName, objectName)
+    s.observe("DeleteObject", err)
+    return err
+}
+
+func (s *instrumentedStorage) DeleteBucket(bucketName string) error {
+    err := s.Storage.DeleteBucket(bucketName)
+    s.observe("DeleteBucket", err)
+    return err
+}
+
+func (s *instrumentedStorage) GetObject(bucketName, objectName string) (io.ReadSeekCloser, dto.FileInfo, error) {
+    object, info, err := s.Storage.GetObject(bucketName, objectName)
+    s.observe("GetObject", err)
+    return object, info, err
+}
+
+func (s *instrumentedStorage) CheckObjectExist(bucketName, objectName string) (bool, dto.FileInfo, error) {
+    exists, info, err := s.Storage.CheckObjectExist(bucketName, objectName)
+    s.observe("CheckObjectExist", err)
+    return exists, info, err
+}
+
+func (s *instrumentedStorage) CheckBucketExists(bucketName string) (bool, error) {
+    exists, err := s.Storage.CheckBucketExists(bucketName)
+    s.observe("CheckBucketExists", err)
+    return exists, err
+}
+
+func (s *instrumentedStorage) ListBuckets() []string {
+    buckets := s.Storage.ListBuckets()
+    s.observe("ListBuckets", nil)
+    return buckets
+}
+
+func (s *instrumentedStorage) ListObjects(bucketName, prefix, marker, delimiter string, maxKeys int) (dto.ListObjectsResponse, error) {
+    response, err := s.Storage.ListObjects(bucketName, prefix, marker, delimiter, maxKeys)
+    s.observe("ListObjects", err)
+    return response, err
+}
+
+func (s *instrumentedStorage) CreateBucket(bucketName string, meta dto.BucketMetadata) error {
+    err := s.Storage.CreateBucket(bucketName, meta)
+    s.observe("CreateBucket", err)
+    return err
+}
+
+func (s *instrumentedStorage) GetBucketMetadata(bucketName string) (dto.BucketMetadata, error) {
+    meta, err := s.Storage.GetBucketMetadata(bucketName)
+    s.observe("GetBucketMetadata", err)
+    return meta, err
+}
+
+func (s *instrumentedStorage) BucketUsage(bucketName string) (dto.BucketUsage, error) {
+    usage, err := s.Storage.BucketUsage(bucketName)
+    s.observe("BucketUsage", err)
+    return usage, err
+}
+
+func (s *instrumentedStorage) CreateMultipartUpload(bucketName, objectName string, meta dto.ObjectMetadata) (string, error) {
+    uploadID, err := s.Storage.CreateMultipartUpload(bucketName, objectName, meta)
+    s.observe("CreateMultipartUpload", err)
+    return uploadID, err
+}
+
+func (s *instrumentedStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader, contentSha256 string) (string, error) {
+    etag, err := s.Storage.UploadPart(bucketName, objectName, uploadID, partNumber, data, contentSha256)
+    s.observe("UploadPart", err)
+    return etag, err
+}
+
+func (s *instrumentedStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (string, error) {
+    etag, err := s.Storage.CompleteMultipartUpload(bucketName, objectName, uploadID, parts)
+    s.observe("CompleteMultipartUpload", err)
+    return etag, err
+}
+
+func (s *instrumentedStorage) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+    err := s.Storage.AbortMultipartUpload(bucketName, objectName, uploadID)
+    s.observe("AbortMultipartUpload", err)
+    return err
+}
+
+func (s *instrumentedStorage) ListParts(bucketName, objectName, uploadID string) ([]dto.Part, error) {
+    parts, err := s.Storage.ListParts(bucketName, objectName, uploadID)
+    s.observe("ListParts", err)
+    return parts, err
+}
+
+func (s *instrumentedStorage) ListMultipartUploads(bucketName string) ([]dto.MultipartUpload, error) {
+    uploads, err := s.Storage.ListMultipartUploads(bucketName)
+    s.observe("ListMultipartUploads", err)
+    return uploads, err
+}
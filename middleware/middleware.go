@@ -1,71 +1,221 @@
 package middleware
 
 import (
+    "bytes"
+    "crypto/subtle"
+    "log/slog"
     "net/http"
+    "net/url"
     "strings"
-    "log"
-    "bytes"
+    "strconv"
+    "time"
+    "my-s3-clone/auth"
+    "my-s3-clone/handlers"
+    "my-s3-clone/requestid"
+    "my-s3-clone/storage"
+
+    "github.com/gorilla/mux"
 )
 
-func BasicAuthMiddleware(next http.Handler) http.Handler {
+// RequestIDMiddleware attribue un identifiant unique à chaque requête : il est placé dans le contexte
+// (logs, corps d'erreur) et renvoyé dans x-amz-request-id, avec l'identifiant d'instance dans x-amz-id-2
+func RequestIDMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        if strings.HasPrefix(r.URL.Path, "/probe-bsign") {
-            next.ServeHTTP(w, r)
-            return
-        }
+        id := requestid.New()
+        w.Header().Set("x-amz-request-id", id)
+        w.Header().Set("x-amz-id-2", requestid.HostID())
+        next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+    })
+}
 
-        // Accepter les requêtes avec AWS4-HMAC-SHA256 dans l'en-tête Authorization
-        if strings.Contains(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
-            next.ServeHTTP(w, r)
-            return
-        }
+// Routes servies sans authentification SigV4 : sonde de santé et métriques Prometheus, ces dernières
+// protégées par RequireBearerToken (noms des routes de router.SetupRouterWithStorage)
+var publicRoutes = map[string]bool{"Probe": true, "Metrics": true}
 
-        // Appliquer l'authentification basique pour les autres routes
-        user, pass, ok := r.BasicAuth()
-        if !ok || user != "accessuser" || pass != "accesspassword" {
-            http.Error(w, "Unauthorized", http.StatusUnauthorized)
+// AuthMiddleware vérifie la signature AWS SigV4 de chaque requête avec les identifiants fournis
+func AuthMiddleware(creds auth.Credentials) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            // Seules les routes publiques sont exemptées, d'après la route retenue par le routeur et non
+            // d'après le chemin : un bucket "probe-bsign..." reste soumis à l'authentification
+            if route := mux.CurrentRoute(r); route != nil && publicRoutes[route.GetName()] {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            logger := requestid.Logger(r.Context())
+            result, err := auth.VerifyRequest(r, creds, time.Now())
+            if err != nil {
+                logger.Warn("authentication failed", "method", r.Method, "path", r.URL.Path, "error", err)
+                handlers.WriteError(w, r, err)
+                return
+            }
+
+            // Le corps signé est vérifié au fil de sa lecture par le handler
+            payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+            switch {
+            case payloadHash == auth.StreamingPayload:
+                decodedLength, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+                if err != nil || decodedLength < 0 {
+                    handlers.WriteError(w, r, auth.ErrMissingContentLength)
+                    return
+                }
+                r.Body = auth.NewChunkVerifier(r.Body, result, decodedLength)
+            case auth.IsSignedPayload(payloadHash):
+                r.Body = auth.NewPayloadVerifier(r.Body, payloadHash)
+            }
+
+            logger.Debug("request authenticated", "access_key", result.AccessKey)
+            next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), result)))
+        })
+    }
+}
+
+// RequireBearerToken ne sert next qu'aux requêtes portant "Authorization: Bearer <token>".
+// Un token vide ferme la route : les noms de buckets ne sont jamais exposés sans configuration explicite.
+func RequireBearerToken(token string, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+        if token == "" || !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+            requestid.Logger(r.Context()).Warn("bearer token rejected", "method", r.Method, "path", r.URL.Path)
+            handlers.WriteError(w, r, storage.ErrAccessDenied)
             return
         }
-
         next.ServeHTTP(w, r)
     })
 }
 
+// Paramètres de requête porteurs de secrets, masqués dans les logs (URL présignées)
+var redactedQueryParams = []string{"X-Amz-Signature", "X-Amz-Credential", "X-Amz-Security-Token"}
+
+// Taille maximale d'un corps de réponse XML recopié dans les logs
+const maxLoggedBodySize = 4 << 10
+
+// LogRequestMiddleware trace chaque requête reçue au niveau debug, sans signature ni identifiants
 func LogRequestMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        log.Printf("Requête reçue 1: %s %s", r.Method, r.RequestURI)
-
-        if len(r.URL.Query()) > 0 {
-            log.Printf("Query Params: %v", r.URL.Query())
-        }
+        logger := requestid.Logger(r.Context())
+        logger.Debug("request received",
+            "method", r.Method,
+            "uri", redactURI(r.URL),
+            "remote_addr", r.RemoteAddr,
+            "content_length", r.ContentLength,
+            "authorization", redactAuthorization(r.Header.Get("Authorization")),
+        )
 
         next.ServeHTTP(w, r)
     })
 }
 
+// L'en-tête Authorization n'est tracé que jusqu'à la signature exclue
+func redactAuthorization(header string) string {
+    if header == "" {
+        return ""
+    }
+    if i := strings.Index(header, "Signature="); i >= 0 {
+        return header[:i] + "Signature=REDACTED"
+    }
+    return "REDACTED"
+}
+
+func redactURI(u *url.URL) string {
+    query := u.Query()
+    redacted := false
+    for _, name := range redactedQueryParams {
+        if query.Has(name) {
+            query.Set(name, "REDACTED")
+            redacted = true
+        }
+    }
+    if !redacted {
+        return u.RequestURI()
+    }
+    return u.EscapedPath() + "?" + query.Encode()
+}
+
+// loggingResponseWriter relève le statut et la taille de la réponse. Le corps n'est recopié
+// (dans la limite de maxLoggedBodySize) que s'il est demandé et que la réponse est du XML :
+// les téléchargements d'objets ne sont jamais dupliqués en mémoire.
 type loggingResponseWriter struct {
     http.ResponseWriter
-    statusCode int
+    statusCode   int
+    wroteHeader  bool
+    bytes        int64
+    captureBody  bool
     responseBody bytes.Buffer
+    truncated    bool
 }
 
 func (lrw *loggingResponseWriter) WriteHeader(code int) {
-    lrw.statusCode = code
+    if !lrw.wroteHeader {
+        lrw.wroteHeader = true
+        lrw.statusCode = code
+        lrw.captureBody = lrw.captureBody && isXMLContentType(lrw.Header().Get("Content-Type"))
+    }
     lrw.ResponseWriter.WriteHeader(code)
 }
 
 func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
-    lrw.responseBody.Write(b) 
-    return lrw.ResponseWriter.Write(b) 
+    if !lrw.wroteHeader {
+        lrw.WriteHeader(http.StatusOK)
+    }
+    if lrw.captureBody {
+        if room := maxLoggedBodySize - lrw.responseBody.Len(); room < len(b) {
+            lrw.responseBody.Write(b[:room])
+            lrw.truncated = true
+            lrw.captureBody = false
+        } else {
+            lrw.responseBody.Write(b)
+        }
+    }
+    n, err := lrw.ResponseWriter.Write(b)
+    lrw.bytes += int64(n)
+    return n, err
 }
 
+// Unwrap donne accès au ResponseWriter d'origine (http.ResponseController)
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+    return lrw.ResponseWriter
+}
+
+func isXMLContentType(contentType string) bool {
+    return strings.Contains(strings.ToLower(contentType), "xml")
+}
+
+// LogResponseMiddleware trace une ligne par requête (statut, taille, durée), au niveau warn pour
+// les erreurs client et error pour les erreurs serveur. Le corps des réponses XML est tracé au niveau debug.
 func LogResponseMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        lrw := &loggingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+        logger := requestid.Logger(r.Context())
+        start := time.Now()
+        lrw := &loggingResponseWriter{
+            ResponseWriter: w,
+            statusCode:     http.StatusOK,
+            captureBody:    logger.Enabled(r.Context(), slog.LevelDebug),
+        }
         next.ServeHTTP(lrw, r)
-        
-        // Log la réponse
-        log.Printf("Response status: %d", lrw.statusCode)
-        log.Printf("Response body: %s", lrw.responseBody.String())
+
+        level := slog.LevelInfo
+        switch {
+        case lrw.statusCode >= 500:
+            level = slog.LevelError
+        case lrw.statusCode >= 400:
+            level = slog.LevelWarn
+        }
+        logger.Log(r.Context(), level, "request completed",
+            "method", r.Method,
+            "path", r.URL.Path,
+            "status", lrw.statusCode,
+            "bytes", lrw.bytes,
+            "duration", time.Since(start),
+        )
+
+        if lrw.responseBody.Len() > 0 {
+            body := lrw.responseBody.String()
+            if lrw.truncated {
+                body += "...(truncated)"
+            }
+            logger.Debug("response body", "body", body)
+        }
     })
 }
@@ -0,0 +1,59 @@
+package requestid
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "log/slog"
+    "os"
+    "strings"
+)
+
+type contextKey struct{}
+
+// Identifiant de l'instance (x-amz-id-2), identique pour toutes les requêtes servies par ce processus
+var hostID = newHostID()
+
+// New génère un identifiant de requête au format S3 : 16 caractères hexadécimaux en majuscules
+func New() string {
+    id := make([]byte, 8)
+    rand.Read(id)
+    return strings.ToUpper(hex.EncodeToString(id))
+}
+
+// HostID retourne l'identifiant de l'instance qui sert les requêtes
+func HostID() string {
+    return hostID
+}
+
+// NewContext retourne une copie de ctx portant l'identifiant de requête id
+func NewContext(ctx context.Context, id string) context.Context {
+    return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext retourne l'identifiant de requête porté par ctx, ou "" s'il n'y en a pas
+func FromContext(ctx context.Context) string {
+    id, _ := ctx.Value(contextKey{}).(string)
+    return id
+}
+
+// Logger retourne le logger par défaut enrichi de l'attribut request_id porté par ctx
+func Logger(ctx context.Context) *slog.Logger {
+    id := FromContext(ctx)
+    if id == "" {
+        return slog.Default()
+    }
+    return slog.Default().With("request_id", id)
+}
+
+// L'identifiant d'instance est dérivé du nom d'hôte (le nom du pod sous Kubernetes)
+func newHostID() string {
+    hostname, err := os.Hostname()
+    if err != nil {
+        hostname = "localhost"
+    }
+    sum := sha256.Sum256([]byte(hostname))
+    return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -2,7 +2,9 @@ package router
 
 import (
     "github.com/gorilla/mux"
+    "my-s3-clone/auth"
     "my-s3-clone/handlers"
+    "my-s3-clone/metrics"
     "my-s3-clone/middleware"
     "my-s3-clone/storage"
     "net/http"
@@ -14,39 +16,68 @@ func SetupRouter() *mux.Router {
     return SetupRouterWithStorage(&storage.FileStorage{})
 }
 
-// SetupRouterWithStorage allows injecting custom storage (e.g., mock storage for tests)
+// SetupRouterWithStorage allows injecting custom storage (e.g., mock storage for tests).
+// Routes are named after the S3 operation they serve, which labels their metrics.
 func SetupRouterWithStorage(s storage.Storage) *mux.Router {
+    reg := metrics.NewRegistry()
+    reg.WatchBuckets(s)
+    s = reg.InstrumentStorage(s)
+    admins := auth.LoadAdminAccessKeys()
+    deletions := handlers.NewBucketDeletions(s)
+
     r := mux.NewRouter()
+    r.MethodNotAllowedHandler = handlers.MethodNotAllowedHandler()
+    r.Use(middleware.RequestIDMiddleware)
+    r.Use(reg.Middleware)
     r.Use(middleware.LogRequestMiddleware)
     r.Use(middleware.LogResponseMiddleware)
+    r.Use(middleware.AuthMiddleware(auth.LoadCredentials()))
 
     // Health check route
     r.HandleFunc("/probe-bsign{suffix:.*}", func(w http.ResponseWriter, r *http.Request) {
         w.Header().Set("Content-Type", "application/xml")
         w.WriteHeader(http.StatusOK)
         w.Write([]byte("<Response></Response>"))
-    }).Methods("GET", "HEAD")
+    }).Methods("GET", "HEAD").Name("Probe")
+
+    // Prometheus metrics, scraped with the METRICS_TOKEN bearer token instead of SigV4
+    r.Handle("/metrics", middleware.RequireBearerToken(metrics.LoadToken(), reg.Handler())).Methods("GET").Name("Metrics")
+
+    // Admin routes, "_admin" can never be a bucket name
+    r.HandleFunc(handlers.BucketDeletionStatusPath, handlers.HandleBucketDeletionStatus(deletions, admins)).Methods("GET").Name("GetBucketDeletionStatus")
 
     // Batch delete route
-    r.HandleFunc("/{bucketName}/", handlers.HandleDeleteObject(s)).Queries("delete", "").Methods("POST")
-
-    // Object-specific routes
-    r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleAddObject(s)).Methods("PUT")
-    r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleCheckObjectExist(s)).Methods("HEAD")
-    r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleDownloadObject(s)).Methods("GET")
-    r.HandleFunc("/{bucketName}/", handlers.HandleListObjects(s)).Methods("GET", "HEAD")
-    r.HandleFunc("/{bucketName}/", handlers.HandleBucketLocation(s)).Queries("location", "").Methods("GET")
-    r.HandleFunc("/{bucketName}/", handlers.HandleBucketLockConfig(s)).Queries("object-lock", "").Methods("GET")
-    r.HandleFunc("/{bucketName}/", handlers.HandleBucketDelimiter(s)).Queries("delimiter", "").Methods("GET")
-    
+    r.HandleFunc("/{bucketName}/", handlers.HandleDeleteObject(s)).Queries("delete", "").Methods("POST").Name("DeleteObjects")
+
+    // Multipart upload routes, registered before the plain object routes they share paths with
+    r.HandleFunc("/{bucketName}/", handlers.HandleListMultipartUploads(s)).Queries("uploads", "").Methods("GET").Name("ListMultipartUploads")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleCreateMultipartUpload(s)).Queries("uploads", "").Methods("POST").Name("CreateMultipartUpload")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleUploadPart(s)).Queries("partNumber", "", "uploadId", "").Methods("PUT").Name("UploadPart")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleCompleteMultipartUpload(s)).Queries("uploadId", "").Methods("POST").Name("CompleteMultipartUpload")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleAbortMultipartUpload(s)).Queries("uploadId", "").Methods("DELETE").Name("AbortMultipartUpload")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleListParts(s)).Queries("uploadId", "").Methods("GET").Name("ListParts")
+
+    // Object-specific routes, the object name may contain slashes (e.g. "logs/2024/10/app.log")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleCopyObject(s)).Headers("x-amz-copy-source", "").Methods("PUT").Name("CopyObject")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleAddObject(s)).Methods("PUT").Name("PutObject")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleCheckObjectExist(s)).Methods("HEAD").Name("HeadObject")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleDownloadObject(s)).Methods("GET").Name("GetObject")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", handlers.HandleDeleteSingleObject(s)).Methods("DELETE").Name("DeleteObject")
+
+    // Bucket configuration routes, registered before ListObjects which matches any query
+    r.HandleFunc("/{bucketName}/", handlers.HandleBucketLocation(s)).Queries("location", "").Methods("GET").Name("GetBucketLocation")
+    r.HandleFunc("/{bucketName}/", handlers.HandleBucketLockConfig(s)).Queries("object-lock", "").Methods("GET").Name("GetObjectLockConfiguration")
+    r.HandleFunc("/{bucketName}/", handlers.HandleBucketVersioning(s)).Queries("versioning", "").Methods("GET").Name("GetBucketVersioning")
+    r.HandleFunc("/{bucketName}/", handlers.HandleListObjects(s)).Methods("GET", "HEAD").Name("ListObjects")
 
     // Bucket-specific routes
-    r.HandleFunc("/{bucketName}/", handlers.HandleGetBucket(s)).Methods("GET")
-    r.HandleFunc("/{bucketName}/", handlers.HandleCreateBucket(s)).Methods("PUT")
-    r.HandleFunc("/{bucketName}/", handlers.HandleDeleteBucket(s)).Methods("DELETE")
+    r.HandleFunc("/{bucketName}/", handlers.HandleGetBucket(s)).Methods("GET").Name("GetBucket")
+    r.HandleFunc("/{bucketName}/", handlers.HandleCreateBucket(s)).Methods("PUT").Name("CreateBucket")
+    r.HandleFunc("/{bucketName}/", handlers.HandleForceDeleteBucket(deletions, admins)).Headers(handlers.ForceDeleteHeader, "true").Methods("DELETE").Name("ForceDeleteBucket")
+    r.HandleFunc("/{bucketName}/", handlers.HandleDeleteBucket(s)).Methods("DELETE").Name("DeleteBucket")
 
     // Route for listing all buckets
-    r.HandleFunc("/", handlers.HandleListBuckets(s)).Methods("GET")
+    r.HandleFunc("/", handlers.HandleListBuckets(s)).Methods("GET").Name("ListBuckets")
 
     return r
 }
@@ -0,0 +1,69 @@
+package storage
+
+import (
+    "net"
+    "strings"
+)
+
+// Longueur d'un nom de bucket selon les règles de nommage S3
+const (
+    minBucketNameLength = 3
+    maxBucketNameLength = 63
+)
+
+// Noms réservés aux répertoires internes : ils ne peuvent jamais devenir des buckets,
+// même si un jour les règles ci-dessous venaient à accepter un "." initial
+var reservedBucketNames = map[string]bool{
+    systemDir:    true,
+    ".minio.sys": true,
+}
+
+// Préfixes et suffixes réservés par S3 (noms de domaine internationalisés, points d'accès...)
+var (
+    reservedBucketPrefixes = []string{"xn--", "sthree-", "amzn-s3-demo-"}
+    reservedBucketSuffixes = []string{"-s3alias", "--ol-s3", ".mrap", "--x-s3"}
+)
+
+// ValidateBucketName vérifie qu'un nouveau nom de bucket respecte les règles de nommage S3 :
+// 3 à 63 caractères, minuscules, chiffres, "." et "-", chaque label commençant et finissant par
+// une lettre ou un chiffre, pas de nom en forme d'adresse IP ni de nom réservé.
+// Retourne ErrInvalidBucketName avec un message précisant la règle enfreinte.
+func ValidateBucketName(bucketName string) error {
+    if reservedBucketNames[bucketName] {
+        return ErrInvalidBucketName.WithMessage("The specified bucket name is reserved.")
+    }
+    if len(bucketName) < minBucketNameLength || len(bucketName) > maxBucketNameLength {
+        return ErrInvalidBucketName.WithMessage("Bucket names must be between 3 and 63 characters long.")
+    }
+    for _, c := range bucketName {
+        if !isLowerAlphanumeric(c) && c != '.' && c != '-' {
+            return ErrInvalidBucketName.WithMessage("Bucket names can consist only of lowercase letters, numbers, dots (.), and hyphens (-).")
+        }
+    }
+    for _, label := range strings.Split(bucketName, ".") {
+        if label == "" {
+            return ErrInvalidBucketName.WithMessage("Bucket names must not contain two adjacent periods or begin or end with a period.")
+        }
+        if !isLowerAlphanumeric(rune(label[0])) || !isLowerAlphanumeric(rune(label[len(label)-1])) {
+            return ErrInvalidBucketName.WithMessage("Bucket names must begin and end with a letter or number, as must each label.")
+        }
+    }
+    if net.ParseIP(bucketName) != nil {
+        return ErrInvalidBucketName.WithMessage("Bucket names must not be formatted as an IP address.")
+    }
+    for _, prefix := range reservedBucketPrefixes {
+        if strings.HasPrefix(bucketName, prefix) {
+            return ErrInvalidBucketName.WithMessage("Bucket names must not start with the reserved prefix " + prefix + ".")
+        }
+    }
+    for _, suffix := range reservedBucketSuffixes {
+        if strings.HasSuffix(bucketName, suffix) {
+            return ErrInvalidBucketName.WithMessage("Bucket names must not end with the reserved suffix " + suffix + ".")
+        }
+    }
+    return nil
+}
+
+func isLowerAlphanumeric(c rune) bool {
+    return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
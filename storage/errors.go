@@ -0,0 +1,81 @@
+package storage
+
+import (
+    "net/http"
+)
+
+// APIError est une erreur du catalogue S3 : le code, le message et le statut HTTP renvoyés au client
+type APIError struct {
+    Code       string
+    Message    string
+    StatusCode int
+
+    base *APIError // erreur du catalogue dont celle-ci est une variante
+}
+
+func (e *APIError) Error() string {
+    return e.Message
+}
+
+// WithMessage retourne une copie de l'erreur avec un message plus précis, le code reste le même
+func (e *APIError) WithMessage(message string) *APIError {
+    return &APIError{Code: e.Code, Message: message, StatusCode: e.StatusCode, base: e}
+}
+
+// Is permet à errors.Is de reconnaître une erreur du catalogue à travers ses variantes
+func (e *APIError) Is(target error) bool {
+    for base := e.base; base != nil; base = base.base {
+        if base == target {
+            return true
+        }
+    }
+    return false
+}
+
+// Erreurs que toute implémentation de Storage doit renvoyer, quel que soit son support :
+// les handlers en déduisent la réponse S3 sans connaître les erreurs propres au backend
+var (
+    ErrBucketNotFound = ErrNoSuchBucket
+    ErrObjectNotFound = ErrNoSuchKey
+    ErrBucketExists   = ErrBucketAlreadyExists
+    ErrInvalidName    = &APIError{Code: "InvalidArgument", Message: "The specified bucket or object name is not valid.", StatusCode: http.StatusBadRequest}
+    // ErrBucketNotEmpty, ErrNoSuchUpload, ErrInvalidPart, ErrInvalidPartOrder et ErrEntityTooSmall
+    // font directement partie du catalogue ci-dessous
+)
+
+// Catalogue des erreurs S3 renvoyées par le serveur
+var (
+    ErrAccessDenied                      = &APIError{Code: "AccessDenied", Message: "Access Denied.", StatusCode: http.StatusForbidden}
+    ErrAuthorizationHeaderMalformed      = &APIError{Code: "AuthorizationHeaderMalformed", Message: "The authorization header you provided is invalid.", StatusCode: http.StatusBadRequest}
+    ErrAuthorizationQueryParametersError = &APIError{Code: "AuthorizationQueryParametersError", Message: "The presigned URL query parameters are invalid.", StatusCode: http.StatusBadRequest}
+    ErrBadDigest                         = &APIError{Code: "BadDigest", Message: "The Content-MD5 you specified did not match what we received.", StatusCode: http.StatusBadRequest}
+    ErrBucketAlreadyExists               = &APIError{Code: "BucketAlreadyExists", Message: "The requested bucket name is not available.", StatusCode: http.StatusConflict}
+    ErrBucketNotEmpty                    = &APIError{Code: "BucketNotEmpty", Message: "The bucket you tried to delete is not empty.", StatusCode: http.StatusConflict}
+    ErrEntityTooSmall                    = &APIError{Code: "EntityTooSmall", Message: "Your proposed upload is smaller than the minimum allowed object size.", StatusCode: http.StatusBadRequest}
+    ErrIncompleteBody                    = &APIError{Code: "IncompleteBody", Message: "You did not provide the number of bytes specified by the Content-Length HTTP header.", StatusCode: http.StatusBadRequest}
+    ErrInternalError                     = &APIError{Code: "InternalError", Message: "We encountered an internal error. Please try again.", StatusCode: http.StatusInternalServerError}
+    ErrInvalidAccessKeyID                = &APIError{Code: "InvalidAccessKeyId", Message: "The AWS access key ID you provided does not exist in our records.", StatusCode: http.StatusForbidden}
+    ErrInvalidArgument                   = &APIError{Code: "InvalidArgument", Message: "Invalid argument.", StatusCode: http.StatusBadRequest}
+    ErrInvalidBucketName                 = &APIError{Code: "InvalidBucketName", Message: "The specified bucket is not valid.", StatusCode: http.StatusBadRequest, base: ErrInvalidName}
+    ErrInvalidDigest                     = &APIError{Code: "InvalidDigest", Message: "The Content-MD5 you specified is not valid.", StatusCode: http.StatusBadRequest}
+    ErrInvalidPart                       = &APIError{Code: "InvalidPart", Message: "One or more of the specified parts could not be found or its entity tag did not match.", StatusCode: http.StatusBadRequest}
+    ErrInvalidPartOrder                  = &APIError{Code: "InvalidPartOrder", Message: "The list of parts was not in ascending order.", StatusCode: http.StatusBadRequest}
+    ErrInvalidRange                      = &APIError{Code: "InvalidRange", Message: "The requested range is not satisfiable.", StatusCode: http.StatusRequestedRangeNotSatisfiable}
+    ErrInvalidRequest                    = &APIError{Code: "InvalidRequest", Message: "Invalid request.", StatusCode: http.StatusBadRequest}
+    ErrKeyTooLong                        = &APIError{Code: "KeyTooLongError", Message: "Your key is too long.", StatusCode: http.StatusBadRequest}
+    ErrMalformedXML                      = &APIError{Code: "MalformedXML", Message: "The XML you provided was not well-formed or did not validate against our published schema.", StatusCode: http.StatusBadRequest}
+    ErrMetadataTooLarge                  = &APIError{Code: "MetadataTooLarge", Message: "Your metadata headers exceed the maximum allowed metadata size.", StatusCode: http.StatusBadRequest}
+    ErrMethodNotAllowed                  = &APIError{Code: "MethodNotAllowed", Message: "The specified method is not allowed against this resource.", StatusCode: http.StatusMethodNotAllowed}
+    ErrMissingContentLength              = &APIError{Code: "MissingContentLength", Message: "You must provide the Content-Length HTTP header.", StatusCode: http.StatusLengthRequired}
+    ErrNoSuchBucket                      = &APIError{Code: "NoSuchBucket", Message: "The specified bucket does not exist.", StatusCode: http.StatusNotFound}
+    ErrNoSuchKey                         = &APIError{Code: "NoSuchKey", Message: "The specified key does not exist.", StatusCode: http.StatusNotFound}
+    ErrNoSuchUpload                      = &APIError{Code: "NoSuchUpload", Message: "The specified multipart upload does not exist.", StatusCode: http.StatusNotFound}
+    ErrNotImplemented                    = &APIError{Code: "NotImplemented", Message: "A header you provided implies functionality that is not implemented.", StatusCode: http.StatusNotImplemented}
+    ErrObjectExistsAsDirectory           = &APIError{Code: "ObjectExistsAsDirectory", Message: "Object name already exists as a prefix of other objects.", StatusCode: http.StatusConflict}
+    ErrObjectLockConfigurationNotFound   = &APIError{Code: "ObjectLockConfigurationNotFoundError", Message: "Object Lock configuration does not exist for this bucket.", StatusCode: http.StatusNotFound}
+    ErrParentIsObject                    = &APIError{Code: "ParentIsObject", Message: "A prefix of the object name is already an object.", StatusCode: http.StatusConflict}
+    ErrPreconditionFailed                = &APIError{Code: "PreconditionFailed", Message: "At least one of the pre-conditions you specified did not hold.", StatusCode: http.StatusPreconditionFailed}
+    ErrRequestTimeTooSkewed              = &APIError{Code: "RequestTimeTooSkewed", Message: "The difference between the request time and the server's time is too large.", StatusCode: http.StatusForbidden}
+    ErrSignatureDoesNotMatch             = &APIError{Code: "SignatureDoesNotMatch", Message: "The request signature we calculated does not match the signature you provided.", StatusCode: http.StatusForbidden}
+    ErrXAmzContentSHA256Mismatch         = &APIError{Code: "XAmzContentSHA256Mismatch", Message: "The provided 'x-amz-content-sha256' header does not match what was computed.", StatusCode: http.StatusBadRequest}
+)
@@ -1,10 +1,13 @@
 package storage
 
 import (
+    "crypto/md5"
+    "encoding/hex"
+    "errors"
+    "sort"
     "strings"
     "os"
     "path/filepath"
-    "log"
     "fmt"
     "io"
     "bufio"  
@@ -14,24 +17,36 @@ import (
 )
 
 // FileStorage implémente l'interface Storage avec un stockage basé sur le système de fichiers
-type FileStorage struct{}
+type FileStorage struct {
+    root  string
+    locks keyLocks
+}
 
 const storageRoot = "/mydata/data"
 
+// NewFileStorage crée un FileStorage enraciné dans root.
+// La valeur zéro de FileStorage utilise storageRoot.
+func NewFileStorage(root string) *FileStorage {
+    return &FileStorage{root: root}
+}
+
+// Répertoire racine contenant les buckets
+func (fs *FileStorage) rootDir() string {
+    if fs.root == "" {
+        return storageRoot
+    }
+    return fs.root
+}
+
 func ProcessChunkedStream(reader io.Reader, writer io.Writer) error {
     bufReader := bufio.NewReader(reader)
-    log.Println("Started processing chunked stream")
-
-    totalBytesProcessed := int64(0)
 
     for {
         // Read chunk size line
         line, err := bufReader.ReadString('\n')
         if err != nil {
-            log.Printf("Error reading chunk size: %v", err)
-            return fmt.Errorf("error reading chunk size: %v", err)
+            return fmt.Errorf("error reading chunk size: %w", err)
         }
-        log.Printf("Received chunk size line: %s", line)
 
         // Parse chunk size (ignore chunk extensions)
         line = strings.TrimSpace(line)
@@ -40,167 +55,396 @@ func ProcessChunkedStream(reader io.Reader, writer io.Writer) error {
 
         chunkSize, err := strconv.ParseInt(chunkSizeHex, 16, 64)
         if err != nil {
-            log.Printf("Error parsing chunk size: %v", err)
-            return fmt.Errorf("error parsing chunk size: %v", err)
+            return fmt.Errorf("error parsing chunk size: %w", err)
         }
 
-        log.Printf("Parsed chunk size: %d", chunkSize)
-
         // End of stream (zero-size chunk)
         if chunkSize == 0 {
-            log.Println("Received final chunk (size 0), finishing")
             break
         }
 
         // Copy chunk data to writer
         if _, err := io.CopyN(writer, bufReader, chunkSize); err != nil {
-            log.Printf("Error reading chunk data: %v", err)
-            return fmt.Errorf("error reading chunk data: %v", err)
+            return fmt.Errorf("error reading chunk data: %w", err)
         }
 
-        totalBytesProcessed += chunkSize
-        log.Printf("Successfully processed a chunk, total bytes processed: %d", totalBytesProcessed)
-
         // Discard the CRLF after the chunk
         if _, err := bufReader.Discard(2); err != nil {
-            log.Printf("Error discarding CRLF: %v", err)
-            return fmt.Errorf("error discarding CRLF: %v", err)
+            return fmt.Errorf("error discarding CRLF: %w", err)
         }
 
-        // Log chunk signature 
-        if len(parts) > 1 {
-            chunkSignature := parts[1]
-            log.Printf("Chunk signature: %s", chunkSignature)
-        }
+        // Les signatures de chunk sont vérifiées en amont (auth.NewChunkVerifier)
     }
 
-    log.Printf("Completed processing chunked stream, total bytes processed: %d", totalBytesProcessed)
     return nil
 }
 
 
-// Ajout d'un objet dans un bucket
-func (fs *FileStorage) AddObject(bucketName, objectName string, data io.Reader, contentSha256 string) error {
-    log.Printf("Starting object upload: %s in bucket: %s", objectName, bucketName)
+// Ajout d'un objet dans un bucket avec ses métadonnées, retourne l'ETag (MD5 du contenu) de l'objet écrit.
+// Comme S3, un PUT sur une clé existante remplace l'objet et ses métadonnées (le dernier écrivain gagne).
+func (fs *FileStorage) AddObject(bucketName, objectName string, data io.Reader, contentSha256 string, meta dto.ObjectMetadata) (string, error) {
+    bucketPath, objectPath, err := fs.objectPath(bucketName, objectName)
+    if err != nil {
+        return "", err
+    }
+    // Refus immédiat, avant de recevoir le contenu, d'une clé en conflit avec un "dossier" existant
+    if err := objectPathConflict(bucketPath, objectPath); err != nil {
+        return "", err
+    }
 
-    objectPath, err := getUniqueObjectPath(bucketName, objectName)
+    // Le contenu est d'abord écrit dans un fichier temporaire, puis renommé :
+    // les lecteurs voient soit l'ancien objet complet, soit le nouveau, jamais un fichier partiel.
+    tmpFile, err := fs.createTempFile()
     if err != nil {
-        log.Printf("Failed to create object path for %s in bucket %s: %v", objectName, bucketName, err)
-        return fmt.Errorf("Failed to create object path: %v", err)
+        return "", fmt.Errorf("Failed to create file: %w", err)
+    }
+    tmpPath := tmpFile.Name()
+    defer os.Remove(tmpPath) // sans effet une fois le fichier renommé
+
+    // Le MD5 est calculé au fil de l'écriture, sans relire le fichier
+    hash := md5.New()
+    if err := writeObjectToFile(data, io.MultiWriter(tmpFile, hash), contentSha256); err != nil {
+        tmpFile.Close()
+        return "", err
     }
+    if err := tmpFile.Close(); err != nil {
+        return "", fmt.Errorf("Failed to write data: %w", err)
+    }
+
+    // Le contenu et les métadonnées sont remplacés ensemble, sous le verrou de la clé
+    lock := fs.locks.of(bucketName, objectName)
+    lock.Lock()
+    defer lock.Unlock()
 
-    log.Printf("Object path created: %s", objectPath)
+    // Les clés du type "logs/2024/app.log" sont rangées dans des sous-répertoires du bucket,
+    // créés une fois le contenu entièrement reçu pour ne rien laisser en cas d'échec
+    if err := placeObject(bucketPath, tmpPath, objectPath); err != nil {
+        return "", err
+    }
 
-    file, err := os.Create(objectPath)
+    etag := hex.EncodeToString(hash.Sum(nil))
+    if err := fs.writeObjectMetadata(bucketName, objectName, objectMetadata{ETag: etag, ObjectMetadata: meta}); err != nil {
+        return "", fmt.Errorf("Failed to persist object metadata: %w", err)
+    }
+
+    return quoteETag(etag), nil
+}
+
+// Copie d'un objet, sans passer par le client. Avec meta nil, les métadonnées de la source sont reprises,
+// sinon elles sont remplacées. Retourne les informations de l'objet copié (ETag, date de modification).
+func (fs *FileStorage) CopyObject(srcBucket, srcObject, dstBucket, dstObject string, meta *dto.ObjectMetadata) (dto.FileInfo, error) {
+    _, srcPath, err := fs.objectPath(srcBucket, srcObject)
+    if err != nil {
+        return nil, err
+    }
+    dstBucketPath, dstPath, err := fs.objectPath(dstBucket, dstObject)
     if err != nil {
-        log.Printf("Failed to create file: %s, error: %v", objectPath, err)
-        return fmt.Errorf("Failed to create file: %v", err)
+        return nil, err
     }
-    defer file.Close()
 
-    log.Printf("Writing data to object: %s", objectPath)
+    src, _, record, err := fs.openObject(srcBucket, srcObject, srcPath)
+    if err != nil {
+        return nil, err
+    }
+    defer src.Close()
+    if meta != nil {
+        record.ObjectMetadata = *meta
+    }
 
-    if err := writeObjectToFile(data, file, contentSha256); err != nil {
-        log.Printf("Error writing object to file: %v", err)
-        return err
+    // Même principe que AddObject : copie dans un fichier temporaire puis renommage atomique,
+    // ce qui permet aussi de copier un objet sur lui-même
+    tmpFile, err := fs.createTempFile()
+    if err != nil {
+        return nil, fmt.Errorf("Failed to create file: %v", err)
+    }
+    tmpPath := tmpFile.Name()
+    defer os.Remove(tmpPath)
+
+    // L'ETag d'une copie est le MD5 de son contenu, même lorsque la source vient d'un upload multipart
+    hash := md5.New()
+    if _, err := io.Copy(io.MultiWriter(tmpFile, hash), src); err != nil {
+        tmpFile.Close()
+        return nil, fmt.Errorf("Failed to copy object: %v", err)
+    }
+    if err := tmpFile.Close(); err != nil {
+        return nil, fmt.Errorf("Failed to copy object: %v", err)
     }
 
-    log.Printf("Successfully uploaded file: %s", objectPath)
-    return nil
-}
+    // Le verrou de la source a été relâché par openObject : un seul verrou de clé est tenu à la fois
+    lock := fs.locks.of(dstBucket, dstObject)
+    lock.Lock()
+    defer lock.Unlock()
+
+    if err := placeObject(dstBucketPath, tmpPath, dstPath); err != nil {
+        return nil, err
+    }
 
-// Fonction pour obtenir un chemin unique si l'objet existe déjà
-func getUniqueObjectPath(bucketName, objectName string) (string, error) {
-    objectPath := filepath.Join(storageRoot, bucketName, objectName)
-    if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-        return objectPath, nil
+    record.ETag = hex.EncodeToString(hash.Sum(nil))
+    if err := fs.writeObjectMetadata(dstBucket, dstObject, record); err != nil {
+        return nil, fmt.Errorf("Failed to persist object metadata: %v", err)
     }
 
-    log.Printf("Object already exists, generating new name for: %s", objectPath)
+    info, err := os.Stat(dstPath)
+    if err != nil {
+        return nil, err
+    }
 
-    objectNameWithoutExt := strings.TrimSuffix(objectName, filepath.Ext(objectName))
-    extension := filepath.Ext(objectName)
-    newObjectName := objectNameWithoutExt
-    suffix := 1
+    return record.fileInfo(info), nil
+}
 
-    for {
-        newObjectName = fmt.Sprintf("%s-%d%s", objectNameWithoutExt, suffix, extension)
-        newObjectPath := filepath.Join(storageRoot, bucketName, newObjectName)
-        if _, err := os.Stat(newObjectPath); os.IsNotExist(err) {
-            return newObjectPath, nil
-        }
-        suffix++
+// Création d'un fichier temporaire sur le même système de fichiers que les buckets,
+// afin que le renommage final soit atomique
+func (fs *FileStorage) createTempFile() (*os.File, error) {
+    tmpDir := filepath.Join(fs.rootDir(), systemDir, "tmp")
+    if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
+        return nil, err
     }
+    file, err := os.CreateTemp(tmpDir, "upload-*")
+    if err != nil {
+        return nil, err
+    }
+    // os.CreateTemp crée le fichier en 0600, on conserve les permissions habituelles des objets
+    if err := file.Chmod(0644); err != nil {
+        file.Close()
+        os.Remove(file.Name())
+        return nil, err
+    }
+    return file, nil
 }
 
 // Fonction qui gère l'écriture du flux dans le fichier
-func writeObjectToFile(data io.Reader, file *os.File, contentSha256 string) error {
+func writeObjectToFile(data io.Reader, file io.Writer, contentSha256 string) error {
     if contentSha256 == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
-        log.Println("Processing as chunked stream")
         if err := ProcessChunkedStream(data, file); err != nil {
-            log.Printf("Failed to write chunked data: %v", err)
-            return fmt.Errorf("Failed to write chunked data: %v", err)
+            return fmt.Errorf("Failed to write chunked data: %w", err)
         }
     } else {
-        log.Println("Processing as regular stream")
         if _, err := io.Copy(file, data); err != nil {
-            log.Printf("Failed to write data: %v", err)
-            return fmt.Errorf("Failed to write data: %v", err)
+            return fmt.Errorf("Failed to write data: %w", err)
         }
     }
     return nil
 }
 
 // Lister les objets dans un bucket
-func (fs *FileStorage) ListObjects(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
-    bucketPath := filepath.Join(storageRoot, bucketName)
-
-    objects, err := filepath.Glob(filepath.Join(bucketPath, prefix+"*"))
+// Les clés contenant des "/" sont stockées dans des sous-répertoires, parcourus récursivement.
+// Les clés sont renvoyées dans l'ordre lexicographique, en commençant après marker ;
+// avec un délimiteur, les clés partageant le même préfixe sont regroupées dans CommonPrefixes.
+func (fs *FileStorage) ListObjects(bucketName, prefix, marker, delimiter string, maxKeys int) (dto.ListObjectsResponse, error) {
+    bucketPath, err := fs.bucketPath(bucketName)
     if err != nil {
-        return dto.ListObjectsResponse{}, fmt.Errorf("error while listing objects: %v", err)
+        return dto.ListObjectsResponse{}, err
     }
 
     response := dto.ListObjectsResponse{
-        Xmlns:       "http://s3.amazonaws.com/doc/2006-03-01/",
-        Name:        bucketName,
-        Prefix:      prefix,
-        Marker:      marker,
-        MaxKeys:     maxKeys,
-        IsTruncated: false,
-        Contents:    make([]dto.Object, 0),
-    }
-
-    for i, object := range objects {
-        if i >= maxKeys {
-            response.IsTruncated = true
-            break
+        Xmlns:          "http://s3.amazonaws.com/doc/2006-03-01/",
+        Name:           bucketName,
+        Prefix:         prefix,
+        Marker:         marker,
+        Delimiter:      delimiter,
+        MaxKeys:        maxKeys,
+        IsTruncated:    false,
+        Contents:       make([]dto.Object, 0),
+        CommonPrefixes: make([]dto.CommonPrefix, 0),
+    }
+
+    lastEntry := ""
+    lastCommonPrefix := ""
+
+    // Élagage des sous-arbres qui ne peuvent contenir aucune clé à renvoyer
+    visitDir := func(dirKey string) bool {
+        if !strings.HasPrefix(dirKey, prefix) && !strings.HasPrefix(prefix, dirKey) {
+            return false
+        }
+        // Toutes les clés sous dirKey sont inférieures ou égales au marker
+        if dirKey <= marker && !strings.HasPrefix(marker, dirKey) {
+            return false
+        }
+        // Toutes les clés sous dirKey ont déjà été regroupées dans un préfixe commun
+        if lastCommonPrefix != "" && strings.HasPrefix(dirKey, lastCommonPrefix) {
+            return false
+        }
+        return true
+    }
+
+    visitFile := func(key, objectPath string) error {
+        if key <= marker || !strings.HasPrefix(key, prefix) {
+            return nil
+        }
+        // Un lien symbolique menant hors du stockage n'est pas un objet (voir checkInsideRoot)
+        if err := fs.checkInsideRoot(objectPath); err != nil {
+            return nil
+        }
+
+        // Regroupement des clés sous un préfixe commun (ex: "logs/" pour "logs/app.log")
+        commonPrefix := ""
+        if delimiter != "" {
+            if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+                commonPrefix = key[:len(prefix)+i+len(delimiter)]
+            }
+        }
+        if commonPrefix != "" && (commonPrefix == lastCommonPrefix || commonPrefix <= marker) {
+            return nil
+        }
+
+        if len(response.Contents)+len(response.CommonPrefixes) >= maxKeys {
+            // max-keys=0 ne renvoie rien, sans indiquer de page suivante
+            response.IsTruncated = maxKeys > 0
+            return errStopListing
+        }
+
+        if commonPrefix != "" {
+            response.CommonPrefixes = append(response.CommonPrefixes, dto.CommonPrefix{Prefix: commonPrefix})
+            lastEntry = commonPrefix
+            lastCommonPrefix = commonPrefix
+            return nil
         }
 
-        fileInfo, err := os.Stat(object)
+        fileInfo, meta, err := fs.statObject(bucketName, key, objectPath)
         if err != nil {
-            return dto.ListObjectsResponse{}, fmt.Errorf("error retrieving file info: %v", err)
+            return err
         }
 
         response.Contents = append(response.Contents, dto.Object{
-            Key:          filepath.Base(object),
+            Key:          key,
             LastModified: fileInfo.ModTime(),
+            ETag:         quoteETag(meta.ETag),
             Size:         int(fileInfo.Size()),
         })
+        lastEntry = key
+        return nil
+    }
+
+    err = walkKeys(bucketPath, "", visitDir, visitFile)
+    if err != nil && err != errStopListing {
+        return dto.ListObjectsResponse{}, fmt.Errorf("error while listing objects: %v", err)
+    }
+
+    // La dernière entrée peut être un préfixe commun : le client ne peut pas toujours
+    // déduire le marker suivant de la dernière clé, on le lui fournit
+    if response.IsTruncated {
+        response.NextMarker = lastEntry
     }
 
     return response, nil
 }
 
+// BucketUsage parcourt le bucket avec os.Stat uniquement : contrairement à ListObjects, aucun fichier
+// de métadonnées n'est lu, et l'ETag des objets anciens n'est pas calculé
+func (fs *FileStorage) BucketUsage(bucketName string) (dto.BucketUsage, error) {
+    var usage dto.BucketUsage
+
+    bucketPath, err := fs.bucketPath(bucketName)
+    if err != nil {
+        return usage, err
+    }
+
+    visitDir := func(dirKey string) bool { return true }
+    visitFile := func(key, objectPath string) error {
+        // Mêmes objets que ListObjects : les liens menant hors du stockage sont ignorés
+        if err := fs.checkInsideRoot(objectPath); err != nil {
+            return nil
+        }
+        fileInfo, err := os.Stat(objectPath)
+        if err != nil {
+            // Objet supprimé pendant le parcours
+            return nil
+        }
+        usage.Objects++
+        usage.Bytes += fileInfo.Size()
+        return nil
+    }
+
+    if err := walkKeys(bucketPath, "", visitDir, visitFile); err != nil {
+        return dto.BucketUsage{}, fmt.Errorf("error while computing bucket usage: %v", err)
+    }
+    return usage, nil
+}
+
+// Taille et métadonnées d'un objet listé, lues ensemble sous le verrou de la clé
+func (fs *FileStorage) statObject(bucketName, objectName, objectPath string) (os.FileInfo, objectMetadata, error) {
+    lock := fs.locks.of(bucketName, objectName)
+    lock.RLock()
+    defer lock.RUnlock()
+
+    fileInfo, err := os.Stat(objectPath)
+    if err != nil {
+        return nil, objectMetadata{}, fmt.Errorf("error retrieving file info: %v", err)
+    }
+    meta, err := fs.objectRecord(bucketName, objectName, objectPath)
+    if err != nil {
+        return nil, objectMetadata{}, fmt.Errorf("error retrieving object ETag: %v", err)
+    }
+    return fileInfo, meta, nil
+}
+
+// errStopListing interrompt le parcours d'un bucket une fois max-keys atteint
+var errStopListing = errors.New("max keys reached")
+
+// walkKeys parcourt les fichiers de dir dans l'ordre lexicographique des clés S3, sans
+// charger tout le bucket en mémoire. Un répertoire "a" contient les clés "a/...", il est donc
+// trié comme "a/" parmi ses voisins : "a-b" < "a/..." < "a0". visitDir décide s'il faut
+// descendre dans un répertoire (sa clé se termine par "/"). Le fichier dirMarker d'un répertoire
+// porte la clé du répertoire elle-même.
+func walkKeys(dir, keyPrefix string, visitDir func(dirKey string) bool, visitFile func(key, path string) error) error {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return err
+    }
+
+    sortKey := func(entry os.DirEntry) string {
+        if entry.Name() == dirMarker {
+            // La clé "a/" précède toutes les clés "a/..."
+            return ""
+        }
+        if entry.IsDir() {
+            return entry.Name() + "/"
+        }
+        return entry.Name()
+    }
+    sort.Slice(entries, func(i, j int) bool {
+        return sortKey(entries[i]) < sortKey(entries[j])
+    })
+
+    for _, entry := range entries {
+        key := keyPrefix + entry.Name()
+        path := filepath.Join(dir, entry.Name())
+
+        if entry.IsDir() {
+            if !visitDir(key + "/") {
+                continue
+            }
+            if err := walkKeys(path, key+"/", visitDir, visitFile); err != nil {
+                return err
+            }
+            continue
+        }
+
+        if entry.Name() == dirMarker {
+            // Objet "dossier/" (voir objectPath) ; la racine du bucket n'en contient jamais
+            if keyPrefix == "" {
+                continue
+            }
+            key = keyPrefix
+        }
+        if err := visitFile(key, path); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
 // Lister les buckets
 func (fs *FileStorage) ListBuckets() []string {
     var buckets []string
-    files, err := os.ReadDir(storageRoot)
+    files, err := os.ReadDir(fs.rootDir())
     if err != nil {
         return buckets
     }
 
     for _, file := range files {
-        if file.IsDir() {
+        // Les répertoires cachés (.s3clone, .minio.sys...) ne sont pas des buckets
+        if file.IsDir() && !strings.HasPrefix(file.Name(), ".") {
             buckets = append(buckets, file.Name())
         }
     }
@@ -208,57 +452,120 @@ func (fs *FileStorage) ListBuckets() []string {
     return buckets
 }
 
-// Créer un bucket
-func (fs *FileStorage) CreateBucket(bucketName string) error {
-    bucketPath := filepath.Join(storageRoot, bucketName)
-    if err := os.MkdirAll(bucketPath, os.ModePerm); err != nil {
+// Créer un bucket, ses métadonnées sont enregistrées avec la date de création et la région par défaut si besoin
+func (fs *FileStorage) CreateBucket(bucketName string, meta dto.BucketMetadata) error {
+    if err := ValidateBucketName(bucketName); err != nil {
+        return err
+    }
+    bucketPath, err := fs.newBucketPath(bucketName)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(fs.rootDir(), os.ModePerm); err != nil {
+        return err
+    }
+    if err := os.Mkdir(bucketPath, os.ModePerm); err != nil {
+        if os.IsExist(err) {
+            return ErrBucketExists
+        }
         return err
     }
+
+    if meta.CreationDate.IsZero() {
+        meta.CreationDate = time.Now().UTC()
+    }
+    if meta.Region == "" {
+        meta.Region = DefaultRegion
+    }
+    if err := fs.writeBucketMetadata(bucketName, meta); err != nil {
+        os.Remove(bucketPath)
+        return fmt.Errorf("could not persist bucket metadata: %w", err)
+    }
     return nil
 }
 
 // Récupération d'un objet dans un bucket
-func (fs *FileStorage) GetObject(bucketName, objectName string) ([]byte, dto.FileInfo, error) {
-	objectPath := filepath.Join(storageRoot, bucketName, objectName)
-	log.Printf("Tentative de récupération de l'objet : %s", objectPath)
-
-	// Lire le fichier
-	data, err := os.ReadFile(objectPath)
-	if err != nil {
-		log.Printf("Erreur lors de la lecture de l'objet: %v", err)
-		return nil, nil, err
-	}
-
-	// Récupérer les métadonnées du fichier
-	FileInfo, err := os.Stat(objectPath)
-	if err != nil {
-		log.Printf("Erreur lors de la récupération des métadonnées du fichier: %v", err)
-		return nil, nil, err
-	}
-
-	// Retourner le contenu du fichier et les métadonnées encapsulées dans fileInfoWrapper
-	return data, &dto.FileInfoWrapper{FileInfo: FileInfo}, nil
+// L'appelant est responsable de la fermeture du lecteur retourné
+func (fs *FileStorage) GetObject(bucketName, objectName string) (io.ReadSeekCloser, dto.FileInfo, error) {
+    _, objectPath, err := fs.objectPath(bucketName, objectName)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    file, fileInfo, meta, err := fs.openObject(bucketName, objectName, objectPath)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    // Retourner le flux du fichier et les métadonnées encapsulées dans FileInfoWrapper
+    return file, meta.fileInfo(fileInfo), nil
+}
+
+// Ouverture d'un objet avec ses métadonnées, lues sous le verrou de la clé pour qu'elles correspondent
+// au contenu ouvert. Le fichier reste lisible une fois le verrou relâché, même s'il est remplacé ensuite.
+func (fs *FileStorage) openObject(bucketName, objectName, objectPath string) (*os.File, os.FileInfo, objectMetadata, error) {
+    lock := fs.locks.of(bucketName, objectName)
+    lock.RLock()
+    defer lock.RUnlock()
+
+    // Ouvrir le fichier sans le charger en mémoire
+    file, err := os.Open(objectPath)
+    if err != nil {
+        if isNotExist(err) {
+            return nil, nil, objectMetadata{}, ErrObjectNotFound
+        }
+        return nil, nil, objectMetadata{}, err
+    }
+
+    // Récupérer les métadonnées du fichier
+    fileInfo, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return nil, nil, objectMetadata{}, fmt.Errorf("could not stat object: %w", err)
+    }
+
+    if fileInfo.IsDir() {
+        file.Close()
+        return nil, nil, objectMetadata{}, ErrObjectNotFound
+    }
+
+    meta, err := fs.objectRecord(bucketName, objectName, objectPath)
+    if err != nil {
+        file.Close()
+        return nil, nil, objectMetadata{}, fmt.Errorf("error retrieving object metadata: %w", err)
+    }
+    return file, fileInfo, meta, nil
 }
 
-// Vérification de l'existence d'un objet dans un bucket
-func (fs *FileStorage) CheckObjectExist(bucketName, objectName string) (bool, time.Time, int64, error) {
-    objectPath := filepath.Join(storageRoot, bucketName, objectName)
+// Vérification de l'existence d'un objet dans un bucket, avec ses métadonnées s'il existe
+func (fs *FileStorage) CheckObjectExist(bucketName, objectName string) (bool, dto.FileInfo, error) {
+    _, objectPath, err := fs.objectPath(bucketName, objectName)
+    if err != nil {
+        return false, nil, err
+    }
+
+    lock := fs.locks.of(bucketName, objectName)
+    lock.RLock()
+    defer lock.RUnlock()
 
     fileInfo, err := os.Stat(objectPath)
-    if os.IsNotExist(err) {
-        return false, time.Time{}, 0, nil
+    if isNotExist(err) || (err == nil && fileInfo.IsDir()) {
+        return false, nil, nil
     } else if err != nil {
-        log.Printf("Error checking object: %v", err)
-        return false, time.Time{}, 0, fmt.Errorf("error checking object existence: %v", err)
+        return false, nil, fmt.Errorf("error checking object existence: %w", err)
     }
 
-    return true, fileInfo.ModTime(), fileInfo.Size(), nil
+    meta, err := fs.objectRecord(bucketName, objectName, objectPath)
+    if err != nil {
+        return false, nil, fmt.Errorf("error retrieving object metadata: %w", err)
+    }
+
+    return true, meta.fileInfo(fileInfo), nil
 }
 
 // Vérification de l'existence d'un bucket
 func (fs *FileStorage) CheckBucketExists(bucketName string) (bool, error) {
-    bucketPath := filepath.Join(storageRoot, bucketName)
-    if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+    if _, err := fs.bucketPath(bucketName); errors.Is(err, ErrBucketNotFound) || errors.Is(err, ErrInvalidName) {
         return false, nil
     } else if err != nil {
         return false, err
@@ -266,40 +573,97 @@ func (fs *FileStorage) CheckBucketExists(bucketName string) (bool, error) {
     return true, nil
 }
 
-// Suppression d'un bucket
+// Suppression d'un bucket. Comme sur S3, seul un bucket sans objet peut être supprimé (ErrBucketNotEmpty) ;
+// les uploads multipart encore en cours sur le bucket sont abandonnés avec lui.
 func (fs *FileStorage) DeleteBucket(bucketName string) error {
-    bucketPath := filepath.Join(storageRoot, bucketName)
-
-    if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
-        log.Printf("Bucket %s does not exist", bucketName)
+    bucketPath, err := fs.bucketPath(bucketName)
+    if err != nil {
         return err
     }
 
-    err := os.RemoveAll(bucketPath)
-    if err != nil {
-        log.Printf("Failed to delete bucket %s: %v", bucketName, err)
-        return err
+    if err := removeEmptyTree(bucketPath); err != nil {
+        if errors.Is(err, ErrBucketNotEmpty) {
+            return err
+        }
+        return fmt.Errorf("could not delete bucket: %w", err)
+    }
+
+    if err := fs.removeMultipartUploads(bucketName); err != nil {
+        return fmt.Errorf("could not delete multipart uploads: %w", err)
+    }
+
+    if err := os.Remove(fs.bucketMetadataPath(bucketName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return fmt.Errorf("could not delete bucket metadata: %w", err)
+    }
+
+    if err := os.RemoveAll(fs.objectMetadataDir(bucketName)); err != nil {
+        return fmt.Errorf("could not delete object metadata: %w", err)
     }
 
-    log.Printf("Bucket %s successfully deleted", bucketName)
     return nil
 }
 
 // Suppression d'un objet dans un bucket
 func (fs *FileStorage) DeleteObject(bucketName, objectName string) error {
-    objectPath := filepath.Join(storageRoot, bucketName, objectName)
+    bucketPath, objectPath, err := fs.objectPath(bucketName, objectName)
+    if err != nil {
+        return err
+    }
 
-    if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-        log.Printf("Object %s does not exist in bucket %s", objectName, bucketName)
-        return fmt.Errorf("object not found: %w", err) // Retourne une erreur "object not found" encapsulant l'erreur 404
+    lock := fs.locks.of(bucketName, objectName)
+    lock.Lock()
+    defer lock.Unlock()
+
+    fileInfo, err := os.Stat(objectPath)
+    if isNotExist(err) || (err == nil && fileInfo.IsDir()) {
+        return ErrObjectNotFound
+    }
+
+    if err := os.Remove(objectPath); err != nil {
+        return fmt.Errorf("could not delete object: %w", err)
     }
 
-    err := os.Remove(objectPath)
+    // Nettoyage des "dossiers" devenus vides, sans jamais supprimer le bucket lui-même
+    removeEmptyParents(filepath.Dir(objectPath), bucketPath)
+
+    if err := fs.deleteObjectMetadata(bucketName, objectName); err != nil {
+        return fmt.Errorf("could not delete object metadata: %w", err)
+    }
+
+    return nil
+}
+
+// Suppression d'une arborescence ne contenant que des répertoires (les "dossiers" restant de clés supprimées).
+// os.Remove échoue sur un répertoire non vide : un objet écrit pendant la suppression n'est jamais effacé.
+func removeEmptyTree(dir string) error {
+    entries, err := os.ReadDir(dir)
     if err != nil {
-        log.Printf("Failed to delete object %s in bucket %s: %v", objectName, bucketName, err)
         return err
     }
-
-    log.Printf("Object %s in bucket %s successfully deleted", objectName, bucketName)
+    for _, entry := range entries {
+        if !entry.IsDir() {
+            return ErrBucketNotEmpty
+        }
+        if err := removeEmptyTree(filepath.Join(dir, entry.Name())); err != nil {
+            return err
+        }
+    }
+    if err := os.Remove(dir); err != nil {
+        if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+            return ErrBucketNotEmpty
+        }
+        return err
+    }
     return nil
-}
\ No newline at end of file
+}
+
+// Suppression des répertoires vides en remontant de dir jusqu'à stopAt (exclu)
+func removeEmptyParents(dir, stopAt string) {
+    for dir != stopAt && strings.HasPrefix(dir, stopAt+string(filepath.Separator)) {
+        // os.Remove échoue sur un répertoire non vide, ce qui arrête la remontée
+        if err := os.Remove(dir); err != nil {
+            return
+        }
+        dir = filepath.Dir(dir)
+    }
+}
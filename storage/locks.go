@@ -0,0 +1,23 @@
+package storage
+
+import (
+    "hash/fnv"
+    "sync"
+)
+
+// Nombre de verrous entre lesquels les clés sont réparties
+const keyLockStripes = 256
+
+// keyLocks sérialise les écritures d'une même clé : le contenu et le fichier de métadonnées d'un objet
+// sont remplacés ensemble, et les lecteurs (verrou partagé) ne voient jamais le contenu d'un écrivain
+// avec l'ETag d'un autre. Les clés sont réparties sur un nombre fixe de verrous, deux clés peuvent donc
+// partager un verrou : il ne faut jamais en tenir deux à la fois.
+type keyLocks [keyLockStripes]sync.RWMutex
+
+func (l *keyLocks) of(bucketName, objectName string) *sync.RWMutex {
+    h := fnv.New32a()
+    h.Write([]byte(bucketName))
+    h.Write([]byte{0})
+    h.Write([]byte(objectName))
+    return &l[h.Sum32()%keyLockStripes]
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+    "crypto/md5"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "my-s3-clone/dto"
+    "os"
+    "path/filepath"
+)
+
+// Répertoire interne (ignoré par ListBuckets) où sont conservées les données propres au serveur
+const systemDir = ".s3clone"
+
+// DefaultRegion est la région des buckets créés sans LocationConstraint
+const DefaultRegion = "us-east-1"
+
+// objectMetadata est l'enregistrement persisté à côté de chaque objet
+type objectMetadata struct {
+    ETag string `json:"etag"`
+    dto.ObjectMetadata
+}
+
+// Lecture des métadonnées d'un objet, os.ErrNotExist si aucune n'a été enregistrée
+func (fs *FileStorage) readObjectMetadata(bucketName, objectName string) (objectMetadata, error) {
+    var meta objectMetadata
+
+    data, err := os.ReadFile(fs.metadataPath(bucketName, objectName))
+    if err != nil {
+        return meta, err
+    }
+    if err := json.Unmarshal(data, &meta); err != nil {
+        return meta, fmt.Errorf("corrupted metadata for %s/%s: %v", bucketName, objectName, err)
+    }
+    return meta, nil
+}
+
+// Écriture des métadonnées d'un objet
+func (fs *FileStorage) writeObjectMetadata(bucketName, objectName string, meta objectMetadata) error {
+    path := fs.metadataPath(bucketName, objectName)
+    if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+        return err
+    }
+    return writeJSONFile(path, meta)
+}
+
+// Suppression des métadonnées d'un objet, sans erreur si elles n'existent pas
+func (fs *FileStorage) deleteObjectMetadata(bucketName, objectName string) error {
+    if err := os.Remove(fs.metadataPath(bucketName, objectName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return err
+    }
+    return nil
+}
+
+// objectRecord retourne les métadonnées d'un objet, ETag compris. Pour les objets écrits avant
+// l'enregistrement des métadonnées, le MD5 est calculé depuis le contenu puis persisté.
+func (fs *FileStorage) objectRecord(bucketName, objectName, objectPath string) (objectMetadata, error) {
+    meta, err := fs.readObjectMetadata(bucketName, objectName)
+    if err == nil && meta.ETag != "" {
+        return meta, nil
+    }
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+        return objectMetadata{}, err
+    }
+
+    file, err := os.Open(objectPath)
+    if err != nil {
+        return objectMetadata{}, err
+    }
+    defer file.Close()
+
+    hash := md5.New()
+    if _, err := io.Copy(hash, file); err != nil {
+        return objectMetadata{}, err
+    }
+
+    meta.ETag = hex.EncodeToString(hash.Sum(nil))
+    if err := fs.writeObjectMetadata(bucketName, objectName, meta); err != nil {
+        return objectMetadata{}, err
+    }
+    return meta, nil
+}
+
+// fileInfo associe les informations du fichier d'un objet à ses métadonnées
+func (meta objectMetadata) fileInfo(info os.FileInfo) dto.FileInfo {
+    return &dto.FileInfoWrapper{FileInfo: info, ETagValue: quoteETag(meta.ETag), MetadataValue: meta.ObjectMetadata}
+}
+
+// ETag au format attendu par les clients S3 (entouré de guillemets)
+func quoteETag(etag string) string {
+    return `"` + etag + `"`
+}
+
+// Écriture des métadonnées d'un bucket
+func (fs *FileStorage) writeBucketMetadata(bucketName string, meta dto.BucketMetadata) error {
+    path := fs.bucketMetadataPath(bucketName)
+    if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+        return err
+    }
+    return writeJSONFile(path, meta)
+}
+
+// GetBucketMetadata retourne les métadonnées enregistrées à la création d'un bucket. Pour les buckets créés
+// avant leur enregistrement, la date de création est approchée par celle du répertoire, puis persistée.
+func (fs *FileStorage) GetBucketMetadata(bucketName string) (dto.BucketMetadata, error) {
+    var meta dto.BucketMetadata
+
+    bucketPath, err := fs.bucketPath(bucketName)
+    if err != nil {
+        return meta, err
+    }
+
+    err = readJSONFile(fs.bucketMetadataPath(bucketName), &meta)
+    if err == nil {
+        return meta, nil
+    } else if !errors.Is(err, os.ErrNotExist) {
+        return meta, fmt.Errorf("corrupted metadata for bucket %s: %v", bucketName, err)
+    }
+
+    info, err := os.Stat(bucketPath)
+    if err != nil {
+        return meta, err
+    }
+    meta = dto.BucketMetadata{CreationDate: info.ModTime().UTC(), Region: DefaultRegion}
+    if err := fs.writeBucketMetadata(bucketName, meta); err != nil {
+        return meta, err
+    }
+    return meta, nil
+}
@@ -0,0 +1,432 @@
+package storage
+
+import (
+    "crypto/md5"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "my-s3-clone/dto"
+)
+
+// Taille minimale de toutes les parties sauf la dernière, comme sur S3
+const minPartSize = 5 << 20
+
+// multipartUpload est l'enregistrement persisté pour chaque upload multipart en cours
+type multipartUpload struct {
+    Bucket    string             `json:"bucket"`
+    Key       string             `json:"key"`
+    Initiated time.Time          `json:"initiated"`
+    Metadata  dto.ObjectMetadata `json:"metadata"` // appliquées à l'objet à la finalisation
+}
+
+// partMetadata est l'enregistrement persisté à côté de chaque partie
+type partMetadata struct {
+    ETag string `json:"etag"`
+    Size int64  `json:"size"`
+}
+
+// Répertoire contenant les uploads multipart en cours, un sous-répertoire par uploadId
+func (fs *FileStorage) multipartRoot() string {
+    return filepath.Join(fs.rootDir(), systemDir, "multipart")
+}
+
+func partPath(uploadDir string, partNumber int) string {
+    return filepath.Join(uploadDir, fmt.Sprintf("part.%05d", partNumber))
+}
+
+// Verrou d'une partie : son fichier et son ETag sont remplacés ensemble, comme le contenu et les
+// métadonnées d'un objet. Les identifiants d'upload étant uniques, ils tiennent lieu de bucket.
+func (fs *FileStorage) partLock(uploadID string, partNumber int) *sync.RWMutex {
+    return fs.locks.of(uploadID, strconv.Itoa(partNumber))
+}
+
+// Création d'un upload multipart, retourne son identifiant. Comme sur S3, les métadonnées de l'objet
+// sont fournies à la création de l'upload.
+func (fs *FileStorage) CreateMultipartUpload(bucketName, objectName string, meta dto.ObjectMetadata) (string, error) {
+    if _, _, err := fs.objectPath(bucketName, objectName); err != nil {
+        return "", err
+    }
+
+    idBytes := make([]byte, 16)
+    if _, err := rand.Read(idBytes); err != nil {
+        return "", fmt.Errorf("failed to generate upload id: %v", err)
+    }
+    uploadID := hex.EncodeToString(idBytes)
+
+    uploadDir := filepath.Join(fs.multipartRoot(), uploadID)
+    if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+        return "", fmt.Errorf("failed to create upload directory: %v", err)
+    }
+
+    upload := multipartUpload{Bucket: bucketName, Key: objectName, Initiated: time.Now().UTC(), Metadata: meta}
+    if err := writeJSONFile(filepath.Join(uploadDir, "upload.json"), upload); err != nil {
+        os.RemoveAll(uploadDir)
+        return "", fmt.Errorf("failed to persist upload: %v", err)
+    }
+
+    return uploadID, nil
+}
+
+// Envoi d'une partie, retourne son ETag. Une partie renvoyée avec le même numéro remplace la précédente.
+func (fs *FileStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader, contentSha256 string) (string, error) {
+    uploadDir, _, err := fs.openMultipartUpload(bucketName, objectName, uploadID)
+    if err != nil {
+        return "", err
+    }
+
+    tmpFile, err := fs.createTempFile()
+    if err != nil {
+        return "", fmt.Errorf("failed to create part file: %v", err)
+    }
+    tmpPath := tmpFile.Name()
+    defer os.Remove(tmpPath)
+
+    hash := md5.New()
+    counter := &countingWriter{}
+    if err := writeObjectToFile(data, io.MultiWriter(tmpFile, hash, counter), contentSha256); err != nil {
+        tmpFile.Close()
+        return "", err
+    }
+    if err := tmpFile.Close(); err != nil {
+        return "", fmt.Errorf("failed to write part: %v", err)
+    }
+
+    lock := fs.partLock(uploadID, partNumber)
+    lock.Lock()
+    defer lock.Unlock()
+
+    path := partPath(uploadDir, partNumber)
+    if err := os.Rename(tmpPath, path); err != nil {
+        return "", fmt.Errorf("failed to store part: %v", err)
+    }
+
+    etag := hex.EncodeToString(hash.Sum(nil))
+    if err := writeJSONFile(path+".json", partMetadata{ETag: etag, Size: counter.n}); err != nil {
+        return "", fmt.Errorf("failed to persist part metadata: %v", err)
+    }
+
+    return quoteETag(etag), nil
+}
+
+// Finalisation d'un upload multipart : les parties sont concaténées dans l'objet final.
+// L'ETag retourné suit le format S3 : MD5 des MD5 des parties, suivi du nombre de parties.
+func (fs *FileStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (string, error) {
+    uploadDir, upload, err := fs.openMultipartUpload(bucketName, objectName, uploadID)
+    if err != nil {
+        return "", err
+    }
+    if len(parts) == 0 {
+        return "", ErrInvalidPart
+    }
+    for i := 1; i < len(parts); i++ {
+        if parts[i].PartNumber <= parts[i-1].PartNumber {
+            return "", ErrInvalidPartOrder
+        }
+    }
+
+    // Le bucket a pu être supprimé depuis la création de l'upload
+    bucketPath, objectPath, err := fs.objectPath(bucketName, objectName)
+    if err != nil {
+        return "", err
+    }
+    if err := objectPathConflict(bucketPath, objectPath); err != nil {
+        return "", err
+    }
+
+    // Assemblage dans un fichier temporaire puis renommage atomique, comme pour un PUT
+    tmpFile, err := fs.createTempFile()
+    if err != nil {
+        return "", fmt.Errorf("Failed to create file: %v", err)
+    }
+    tmpPath := tmpFile.Name()
+    defer os.Remove(tmpPath)
+
+    etagsHash := md5.New()
+    for i, part := range parts {
+        rawETag, err := fs.appendPart(tmpFile, uploadDir, uploadID, part, i == len(parts)-1)
+        if err != nil {
+            tmpFile.Close()
+            return "", err
+        }
+        etagsHash.Write(rawETag)
+    }
+    if err := tmpFile.Close(); err != nil {
+        return "", fmt.Errorf("failed to assemble parts: %v", err)
+    }
+
+    lock := fs.locks.of(bucketName, objectName)
+    lock.Lock()
+    defer lock.Unlock()
+
+    if err := placeObject(bucketPath, tmpPath, objectPath); err != nil {
+        return "", err
+    }
+
+    etag := fmt.Sprintf("%s-%d", hex.EncodeToString(etagsHash.Sum(nil)), len(parts))
+    if err := fs.writeObjectMetadata(bucketName, objectName, objectMetadata{ETag: etag, ObjectMetadata: upload.Metadata}); err != nil {
+        return "", fmt.Errorf("Failed to persist object metadata: %v", err)
+    }
+
+    // L'objet est complet : un échec du nettoyage ne doit pas être rapporté comme un échec de l'upload
+    os.RemoveAll(uploadDir)
+
+    return quoteETag(etag), nil
+}
+
+// Vérifie une partie demandée par CompleteMultipartUpload et la recopie dans dst, retourne son ETag brut.
+// La vérification et la copie se font sous le verrou de la partie : un renvoi simultané de la même
+// partie ne peut pas faire assembler son contenu avec l'ETag vérifié de la précédente.
+func (fs *FileStorage) appendPart(dst io.Writer, uploadDir, uploadID string, part dto.CompletedPart, last bool) ([]byte, error) {
+    lock := fs.partLock(uploadID, part.PartNumber)
+    lock.RLock()
+    defer lock.RUnlock()
+
+    path := partPath(uploadDir, part.PartNumber)
+    var meta partMetadata
+    if err := readJSONFile(path+".json", &meta); err != nil {
+        if errors.Is(err, os.ErrNotExist) {
+            return nil, ErrInvalidPart
+        }
+        return nil, err
+    }
+    if strings.Trim(part.ETag, `"`) != meta.ETag {
+        return nil, ErrInvalidPart
+    }
+    if meta.Size < minPartSize && !last {
+        return nil, ErrEntityTooSmall
+    }
+
+    rawETag, err := hex.DecodeString(meta.ETag)
+    if err != nil {
+        return nil, fmt.Errorf("corrupted part metadata: %v", err)
+    }
+    if err := appendFile(dst, path); err != nil {
+        return nil, fmt.Errorf("failed to assemble parts: %v", err)
+    }
+    return rawETag, nil
+}
+
+// Abandon d'un upload multipart, les parties envoyées sont supprimées
+func (fs *FileStorage) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+    uploadDir, _, err := fs.openMultipartUpload(bucketName, objectName, uploadID)
+    if err != nil {
+        return err
+    }
+
+    if err := os.RemoveAll(uploadDir); err != nil {
+        return fmt.Errorf("failed to abort multipart upload: %v", err)
+    }
+
+    return nil
+}
+
+// Liste des parties d'un upload, triées par numéro
+func (fs *FileStorage) ListParts(bucketName, objectName, uploadID string) ([]dto.Part, error) {
+    uploadDir, _, err := fs.openMultipartUpload(bucketName, objectName, uploadID)
+    if err != nil {
+        return nil, err
+    }
+
+    entries, err := os.ReadDir(uploadDir)
+    if err != nil {
+        return nil, err
+    }
+
+    parts := make([]dto.Part, 0)
+    for _, entry := range entries {
+        numberStr, ok := strings.CutPrefix(entry.Name(), "part.")
+        if !ok || strings.HasSuffix(numberStr, ".json") {
+            continue
+        }
+        partNumber, err := strconv.Atoi(numberStr)
+        if err != nil {
+            continue
+        }
+
+        meta, info, err := fs.statPart(uploadDir, uploadID, partNumber)
+        if err != nil {
+            // Partie en cours d'écriture, pas encore visible
+            continue
+        }
+
+        parts = append(parts, dto.Part{
+            PartNumber:   partNumber,
+            LastModified: info.ModTime(),
+            ETag:         quoteETag(meta.ETag),
+            Size:         meta.Size,
+        })
+    }
+
+    sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+    return parts, nil
+}
+
+// Métadonnées et date d'une partie, lues ensemble sous son verrou
+func (fs *FileStorage) statPart(uploadDir, uploadID string, partNumber int) (partMetadata, os.FileInfo, error) {
+    lock := fs.partLock(uploadID, partNumber)
+    lock.RLock()
+    defer lock.RUnlock()
+
+    path := partPath(uploadDir, partNumber)
+    var meta partMetadata
+    if err := readJSONFile(path+".json", &meta); err != nil {
+        return partMetadata{}, nil, err
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return partMetadata{}, nil, err
+    }
+    return meta, info, nil
+}
+
+// Liste des uploads multipart en cours dans un bucket, triés par clé puis par date de création
+func (fs *FileStorage) ListMultipartUploads(bucketName string) ([]dto.MultipartUpload, error) {
+    if _, err := fs.bucketPath(bucketName); err != nil {
+        return nil, err
+    }
+    uploads := make([]dto.MultipartUpload, 0)
+
+    entries, err := os.ReadDir(fs.multipartRoot())
+    if err != nil {
+        if errors.Is(err, os.ErrNotExist) {
+            return uploads, nil
+        }
+        return nil, err
+    }
+
+    for _, entry := range entries {
+        var upload multipartUpload
+        if err := readJSONFile(filepath.Join(fs.multipartRoot(), entry.Name(), "upload.json"), &upload); err != nil {
+            continue
+        }
+        if upload.Bucket != bucketName {
+            continue
+        }
+        uploads = append(uploads, dto.MultipartUpload{
+            Key:       upload.Key,
+            UploadId:  entry.Name(),
+            Initiated: upload.Initiated,
+        })
+    }
+
+    sort.Slice(uploads, func(i, j int) bool {
+        if uploads[i].Key != uploads[j].Key {
+            return uploads[i].Key < uploads[j].Key
+        }
+        return uploads[i].Initiated.Before(uploads[j].Initiated)
+    })
+    return uploads, nil
+}
+
+// Suppression des uploads en cours sur un bucket, appelée une fois le bucket supprimé
+func (fs *FileStorage) removeMultipartUploads(bucketName string) error {
+    entries, err := os.ReadDir(fs.multipartRoot())
+    if err != nil {
+        if errors.Is(err, os.ErrNotExist) {
+            return nil
+        }
+        return err
+    }
+
+    for _, entry := range entries {
+        var upload multipartUpload
+        uploadDir := filepath.Join(fs.multipartRoot(), entry.Name())
+        if err := readJSONFile(filepath.Join(uploadDir, "upload.json"), &upload); err != nil || upload.Bucket != bucketName {
+            continue
+        }
+        if err := os.RemoveAll(uploadDir); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Vérifie qu'un upload existe et correspond au bucket et à la clé demandés
+func (fs *FileStorage) openMultipartUpload(bucketName, objectName, uploadID string) (string, multipartUpload, error) {
+    var upload multipartUpload
+
+    // L'identifiant vient du client : il ne doit pas permettre de sortir du répertoire multipart
+    if _, err := hex.DecodeString(uploadID); err != nil || len(uploadID) != 32 {
+        return "", upload, ErrNoSuchUpload
+    }
+
+    uploadDir := filepath.Join(fs.multipartRoot(), uploadID)
+    if err := readJSONFile(filepath.Join(uploadDir, "upload.json"), &upload); err != nil {
+        if errors.Is(err, os.ErrNotExist) {
+            return "", upload, ErrNoSuchUpload
+        }
+        return "", upload, err
+    }
+    if upload.Bucket != bucketName || upload.Key != objectName {
+        return "", upload, ErrNoSuchUpload
+    }
+    return uploadDir, upload, nil
+}
+
+// Ajout du contenu du fichier path à la fin de dst
+func appendFile(dst io.Writer, path string) error {
+    src, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer src.Close()
+
+    _, err = io.Copy(dst, src)
+    return err
+}
+
+// countingWriter compte les octets écrits
+type countingWriter struct {
+    n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+    cw.n += int64(len(p))
+    return len(p), nil
+}
+
+// Écriture atomique d'un fichier JSON : le contenu est écrit dans un fichier temporaire du même
+// répertoire (caché, donc ignoré des listages) puis renommé, un lecteur ne voit jamais de fichier partiel
+func writeJSONFile(path string, v interface{}) error {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+
+    tmpFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+    if err != nil {
+        return err
+    }
+    tmpPath := tmpFile.Name()
+    defer os.Remove(tmpPath) // sans effet une fois le fichier renommé
+
+    if _, err := tmpFile.Write(data); err != nil {
+        tmpFile.Close()
+        return err
+    }
+    if err := tmpFile.Close(); err != nil {
+        return err
+    }
+    if err := os.Chmod(tmpPath, 0644); err != nil {
+        return err
+    }
+    return os.Rename(tmpPath, path)
+}
+
+func readJSONFile(path string, v interface{}) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(data, v)
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "syscall"
+)
+
+// Toute conversion d'un nom de bucket ou d'une clé venant du client en chemin du système de fichiers
+// est faite dans ce fichier : bucketPath, newBucketPath et objectPath pour les données, puis les chemins
+// des fichiers internes qui en dérivent. Aucune autre fonction ne doit faire de filepath.Join sur ces valeurs.
+var (
+    errInvalidObjectKey = ErrInvalidName.WithMessage("The specified object key is not valid.")
+    errPathOutsideRoot  = ErrInvalidName.WithMessage("The specified bucket or object name resolves outside of the storage.")
+)
+
+// Chemin d'un bucket existant. Un nom vide, contenant un séparateur ou commençant par "."
+// (répertoires internes comme .s3clone) ne désigne jamais un bucket. Les règles de nommage S3
+// ne s'appliquent qu'à la création (voir ValidateBucketName) pour ne pas rendre inaccessibles
+// les buckets créés avant leur mise en place.
+func (fs *FileStorage) bucketPath(bucketName string) (string, error) {
+    if !isValidBucketName(bucketName) {
+        return "", ErrInvalidBucketName
+    }
+    path := filepath.Join(fs.rootDir(), bucketName)
+    if err := fs.checkInsideRoot(path); err != nil {
+        return "", err
+    }
+    info, err := os.Stat(path)
+    if os.IsNotExist(err) || (err == nil && !info.IsDir()) {
+        return "", ErrBucketNotFound
+    } else if err != nil {
+        return "", err
+    }
+    return path, nil
+}
+
+// Chemin d'un bucket à créer, qui peut donc ne pas encore exister
+func (fs *FileStorage) newBucketPath(bucketName string) (string, error) {
+    if !isValidBucketName(bucketName) {
+        return "", ErrInvalidBucketName
+    }
+    path := filepath.Join(fs.rootDir(), bucketName)
+    if err := fs.checkInsideRoot(path); err != nil {
+        return "", err
+    }
+    return path, nil
+}
+
+// Chemin d'un objet dans un bucket existant, avec le chemin du bucket
+func (fs *FileStorage) objectPath(bucketName, objectName string) (string, string, error) {
+    bucketPath, err := fs.bucketPath(bucketName)
+    if err != nil {
+        return "", "", err
+    }
+    if err := validateObjectKey(objectName); err != nil {
+        return "", "", err
+    }
+    path := filepath.Join(bucketPath, filepath.FromSlash(objectName))
+    if strings.HasSuffix(objectName, "/") {
+        // "dossier/" est un objet à part entière (c'est ainsi que les clients S3 créent les dossiers),
+        // rangé dans le répertoire du dossier sous un nom qu'aucune clé ne peut utiliser
+        path = filepath.Join(path, dirMarker)
+    }
+    if !isWithin(bucketPath, path) {
+        return "", "", errInvalidObjectKey
+    }
+    if err := fs.checkInsideRoot(path); err != nil {
+        return "", "", err
+    }
+    return bucketPath, path, nil
+}
+
+func isValidBucketName(bucketName string) bool {
+    return bucketName != "" && !strings.HasPrefix(bucketName, ".") && !strings.ContainsAny(bucketName, `/\`)
+}
+
+// Les chemins internes ci-dessous ne reçoivent que des noms de bucket déjà acceptés par bucketPath
+// ou newBucketPath : sans séparateur ni "." initial, ils restent dans le répertoire interne.
+
+// Répertoire des fichiers de métadonnées des objets d'un bucket
+func (fs *FileStorage) objectMetadataDir(bucketName string) string {
+    return filepath.Join(fs.rootDir(), systemDir, "meta", bucketName)
+}
+
+// Chemin du fichier de métadonnées associé à un objet.
+// Le nom est dérivé d'un hash de la clé : les clés imbriquées ("a/b") ne peuvent pas
+// entrer en collision avec les fichiers de métadonnées d'autres clés ("a" -> "a.json").
+func (fs *FileStorage) metadataPath(bucketName, objectName string) string {
+    sum := sha256.Sum256([]byte(objectName))
+    name := hex.EncodeToString(sum[:])
+    return filepath.Join(fs.objectMetadataDir(bucketName), name[:2], name+".json")
+}
+
+// Chemin du fichier de métadonnées d'un bucket
+func (fs *FileStorage) bucketMetadataPath(bucketName string) string {
+    return filepath.Join(fs.rootDir(), systemDir, "buckets", bucketName+".json")
+}
+
+// Nom du fichier contenant un objet dont la clé se termine par "/", dans le répertoire correspondant
+const dirMarker = systemDir + ".dir"
+
+// Longueur maximale d'une clé en octets, comme S3. La longueur de chaque segment est bornée par le
+// système de fichiers (255 octets en général) : un dépassement est rapporté par placeObject.
+const maxObjectKeyLength = 1024
+
+// Une clé est rangée dans l'arborescence du bucket, un segment de la clé par répertoire :
+// les segments vides ("a//b", "/a"), "." et ".." sont refusés car le système de fichiers les
+// interpréterait au lieu de les stocker, tout comme l'octet NUL et le nom réservé dirMarker.
+// Seul un "/" final est accepté ("dossier/"), voir objectPath.
+func validateObjectKey(objectName string) error {
+    if objectName == "" || strings.ContainsRune(objectName, 0) {
+        return errInvalidObjectKey
+    }
+    if len(objectName) > maxObjectKeyLength {
+        return ErrKeyTooLong
+    }
+    if os.PathSeparator != '/' && strings.ContainsRune(objectName, os.PathSeparator) {
+        return errInvalidObjectKey
+    }
+    for _, segment := range strings.Split(strings.TrimSuffix(objectName, "/"), "/") {
+        if segment == "" || segment == "." || segment == ".." || segment == dirMarker {
+            return errInvalidObjectKey
+        }
+    }
+    return nil
+}
+
+// checkInsideRoot vérifie qu'aucun composant existant de path n'est un lien symbolique menant
+// hors de la racine du stockage. Les composants qui n'existent pas encore seront créés comme
+// de vrais répertoires par l'appelant.
+func (fs *FileStorage) checkInsideRoot(path string) error {
+    root := fs.rootDir()
+    if !isWithin(root, path) {
+        return errPathOutsideRoot
+    }
+    realRoot, err := filepath.EvalSymlinks(root)
+    if err != nil {
+        // Sans racine, il n'y a aucun lien à suivre
+        return nil
+    }
+
+    rel, _ := filepath.Rel(root, path)
+    current := root
+    for _, name := range strings.Split(rel, string(filepath.Separator)) {
+        current = filepath.Join(current, name)
+        info, err := os.Lstat(current)
+        if err != nil {
+            // Composant absent ou inaccessible : les opérations suivantes échoueront de la même façon
+            return nil
+        }
+        if info.Mode()&os.ModeSymlink == 0 {
+            continue
+        }
+        target, err := filepath.EvalSymlinks(current)
+        if err != nil || !isWithin(realRoot, target) {
+            // Le détail n'est destiné qu'aux logs du handler, le client ne reçoit que errPathOutsideRoot
+            return fmt.Errorf("%w: symlink %s resolves to %q", errPathOutsideRoot, current, target)
+        }
+    }
+    return nil
+}
+
+// isWithin indique si path est base ou se trouve sous base
+func isWithin(base, path string) bool {
+    rel, err := filepath.Rel(base, path)
+    return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// Une clé ne peut pas désigner à la fois un objet et un "dossier" contenant d'autres objets :
+// écrire "a" quand "a/b" existe, ou "a/b" quand "a" existe, est une erreur du client
+func objectPathConflict(bucketPath, objectPath string) error {
+    if info, err := os.Stat(objectPath); err == nil && info.IsDir() {
+        return ErrObjectExistsAsDirectory
+    }
+    for dir := filepath.Dir(objectPath); dir != bucketPath && isWithin(bucketPath, dir); dir = filepath.Dir(dir) {
+        if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+            return ErrParentIsObject
+        }
+    }
+    return nil
+}
+
+// Mise en place d'un objet écrit dans le fichier temporaire tmpPath : création des répertoires
+// parents puis renommage atomique, les conflits entre objets et "dossiers" ainsi que les segments
+// trop longs pour le système de fichiers devenant des erreurs client
+func placeObject(bucketPath, tmpPath, objectPath string) error {
+    if err := os.MkdirAll(filepath.Dir(objectPath), os.ModePerm); err != nil {
+        if errors.Is(err, syscall.ENAMETOOLONG) {
+            return ErrKeyTooLong
+        }
+        if conflict := objectPathConflict(bucketPath, objectPath); conflict != nil {
+            return conflict
+        }
+        return fmt.Errorf("Failed to create object path: %v", err)
+    }
+    if err := os.Rename(tmpPath, objectPath); err != nil {
+        if errors.Is(err, syscall.ENAMETOOLONG) {
+            return ErrKeyTooLong
+        }
+        if conflict := objectPathConflict(bucketPath, objectPath); conflict != nil {
+            return conflict
+        }
+        return fmt.Errorf("Failed to store object: %v", err)
+    }
+    return nil
+}
+
+// isNotExist considère aussi comme absent un objet dont un parent est un fichier ("a/b" quand "a" est un objet),
+// ou dont un segment est trop long pour le système de fichiers : placeObject n'a pas pu l'écrire
+func isNotExist(err error) bool {
+    return os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) || errors.Is(err, syscall.ENAMETOOLONG)
+}
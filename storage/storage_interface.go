@@ -2,22 +2,39 @@ package storage
 
 import (
 	"io"
-	"time"
 	"my-s3-clone/dto"
 
 )
 
-// Storage interface définissant les méthodes de gestion des objets et des buckets
+// Storage interface définissant les méthodes de gestion des objets et des buckets.
+// Les implémentations signalent les cas attendus avec les erreurs du package (ErrBucketNotFound,
+// ErrObjectNotFound, ErrBucketExists, ErrInvalidName...), jamais avec des erreurs propres à leur support.
+// CheckBucketExists et CheckObjectExist renvoient false sans erreur pour un bucket ou un objet absent.
+// Les métadonnées passées à AddObject (ou à CreateMultipartUpload) sont restituées par FileInfo.Metadata ;
+// CopyObject reprend celles de la source lorsque meta est nil. CreateBucket enregistre les métadonnées du bucket,
+// restituées par GetBucketMetadata avec la date de création et la région renseignées.
+// BucketUsage compte les objets d'un bucket sans lire leurs métadonnées, il doit rester peu coûteux.
 type Storage interface {
-    AddObject(bucketName, objectName string, data io.Reader, contentSha256 string) error
+    AddObject(bucketName, objectName string, data io.Reader, contentSha256 string, meta dto.ObjectMetadata) (string, error)
+    CopyObject(srcBucket, srcObject, dstBucket, dstObject string, meta *dto.ObjectMetadata) (dto.FileInfo, error)
     DeleteObject(bucketName, objectName string) error
     DeleteBucket(bucketName string) error
-    GetObject(bucketName, objectName string) ([]byte, dto.FileInfo, error)
-    CheckObjectExist(bucketName, objectName string) (bool, time.Time, int64, error)
+    GetObject(bucketName, objectName string) (io.ReadSeekCloser, dto.FileInfo, error)
+    CheckObjectExist(bucketName, objectName string) (bool, dto.FileInfo, error)
     CheckBucketExists(bucketName string) (bool, error)
     ListBuckets() []string
-    ListObjects(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error)
-    CreateBucket(bucketName string) error
+    ListObjects(bucketName, prefix, marker, delimiter string, maxKeys int) (dto.ListObjectsResponse, error)
+    CreateBucket(bucketName string, meta dto.BucketMetadata) error
+    GetBucketMetadata(bucketName string) (dto.BucketMetadata, error)
+    BucketUsage(bucketName string) (dto.BucketUsage, error)
+
+    // Upload multipart
+    CreateMultipartUpload(bucketName, objectName string, meta dto.ObjectMetadata) (string, error)
+    UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader, contentSha256 string) (string, error)
+    CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (string, error)
+    AbortMultipartUpload(bucketName, objectName, uploadID string) error
+    ListParts(bucketName, objectName, uploadID string) ([]dto.Part, error)
+    ListMultipartUploads(bucketName string) ([]dto.MultipartUpload, error)
 }
 
 
@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/logging"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// captureLogs redirects the default slog logger to a buffer for the duration of the test
+func captureLogs(t *testing.T, level, format string) *bytes.Buffer {
+	t.Helper()
+
+	var logs bytes.Buffer
+	handler, err := logging.NewHandler(&logs, level, format)
+	if err != nil {
+		t.Fatalf("could not create log handler: %v", err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &logs
+}
+
+// JSON log records whose message is msg
+func logRecords(t *testing.T, logs *bytes.Buffer, msg string) []map[string]interface{} {
+	t.Helper()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(logs.Bytes()))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("expected JSON log lines but got %q: %v", scanner.Text(), err)
+		}
+		if record["msg"] == msg {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func TestLoggingConfiguration(t *testing.T) {
+	for _, tc := range []struct {
+		level, format string
+	}{
+		{"", ""},
+		{"debug", "json"},
+		{"INFO", "text"},
+		{"warn", "JSON"},
+		{"error", ""},
+	} {
+		if _, err := logging.NewHandler(&bytes.Buffer{}, tc.level, tc.format); err != nil {
+			t.Errorf("expected level %q and format %q to be accepted: %v", tc.level, tc.format, err)
+		}
+	}
+
+	if _, err := logging.NewHandler(&bytes.Buffer{}, "verbose", "json"); err == nil {
+		t.Errorf("expected an unknown level to be rejected")
+	}
+	if _, err := logging.NewHandler(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Errorf("expected an unknown format to be rejected")
+	}
+
+	logs := captureLogs(t, "warn", "json")
+	slog.Info("hidden")
+	slog.Warn("shown")
+	if len(logRecords(t, logs, "hidden")) != 0 || len(logRecords(t, logs, "shown")) != 1 {
+		t.Errorf("expected only records at or above the configured level, got %q", logs.String())
+	}
+}
+
+func TestResponseLogging(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+	r := router.SetupRouterWithStorage(fs)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Binary object bodies are never logged, even at debug level
+	logs := captureLogs(t, "debug", "json")
+	content := bytes.Repeat([]byte("binary-secret-content"), 1024)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("object-with-a-rather-long-name-to-fill-the-listing-%03d.bin", i)
+		if _, err := fs.AddObject("test-bucket", key, bytes.NewReader(content), "", dto.ObjectMetadata{}); err != nil {
+			t.Fatalf("could not add object: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/test-bucket/object-with-a-rather-long-name-to-fill-the-listing-000.bin", nil)
+	signRequest(req)
+	if rr := serve(req); rr.Code != http.StatusOK {
+		t.Fatalf("expected GET to succeed, got %d", rr.Code)
+	}
+	if strings.Contains(logs.String(), "binary-secret-content") {
+		t.Errorf("expected object content to stay out of the logs")
+	}
+
+	completed := logRecords(t, logs, "request completed")
+	if len(completed) != 1 {
+		t.Fatalf("expected one completion record per request, got %d", len(completed))
+	}
+	if completed[0]["status"] != float64(http.StatusOK) || completed[0]["bytes"] != float64(len(content)) || completed[0]["request_id"] == nil {
+		t.Errorf("expected status, size and request ID in the completion record, got %v", completed[0])
+	}
+
+	// XML bodies are logged at debug level, capped in size
+	logs.Reset()
+	req = httptest.NewRequest("GET", "/test-bucket/", nil)
+	signRequest(req)
+	rr := serve(req)
+	if rr.Code != http.StatusOK || rr.Body.Len() <= 4096 {
+		t.Fatalf("expected a listing larger than the log cap, got %d (%d bytes)", rr.Code, rr.Body.Len())
+	}
+	bodies := logRecords(t, logs, "response body")
+	if len(bodies) != 1 {
+		t.Fatalf("expected the XML body to be logged once, got %d records", len(bodies))
+	}
+	body, _ := bodies[0]["body"].(string)
+	if !strings.HasPrefix(body, "<ListBucketResult") || !strings.HasSuffix(body, "...(truncated)") || len(body) > 4096+len("...(truncated)") {
+		t.Errorf("expected a truncated XML body, got %d bytes ending with %q", len(body), body[len(body)-20:])
+	}
+
+	// Response bodies are not captured at all above debug level
+	logs = captureLogs(t, "info", "json")
+	req = httptest.NewRequest("GET", "/test-bucket/", nil)
+	signRequest(req)
+	serve(req)
+	if len(logRecords(t, logs, "response body")) != 0 || len(logRecords(t, logs, "request completed")) != 1 {
+		t.Errorf("expected only the completion record at info level, got %q", logs.String())
+	}
+
+	// Client errors are logged as warnings
+	logs.Reset()
+	req = httptest.NewRequest("GET", "/test-bucket/missing.bin", nil)
+	signRequest(req)
+	serve(req)
+	if completed := logRecords(t, logs, "request completed"); len(completed) != 1 || completed[0]["level"] != "WARN" {
+		t.Errorf("expected a WARN completion record for a 404, got %v", completed)
+	}
+}
+
+// Storage failures are logged once, by the handler, with the ID of the request that hit them
+func TestStorageErrorsLoggedWithRequestID(t *testing.T) {
+	root := t.TempDir()
+	fs := storage.NewFileStorage(root)
+	if err := fs.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	if _, err := fs.AddObject("test-bucket", "key.txt", strings.NewReader("data"), "", dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not add object: %v", err)
+	}
+	// Corrupted metadata make every read of the object fail
+	filepath.Walk(filepath.Join(root, ".s3clone", "meta"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			os.WriteFile(path, []byte("{"), 0644)
+		}
+		return nil
+	})
+
+	r := router.SetupRouterWithStorage(fs)
+	logs := captureLogs(t, "debug", "json")
+	req := httptest.NewRequest("GET", "/test-bucket/key.txt", nil)
+	signRequest(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected a 500 for corrupted metadata, got %d", rr.Code)
+	}
+
+	requestID := rr.Header().Get("x-amz-request-id")
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		if !strings.Contains(line, `"request_id":"`+requestID+`"`) {
+			t.Errorf("expected every record to carry the request ID %s, got %s", requestID, line)
+		}
+	}
+	records := logRecords(t, logs, "internal error")
+	if len(records) != 1 {
+		t.Fatalf("expected the failure to be logged once, by the handler, got %s", logs.String())
+	}
+	if message, _ := records[0]["error"].(string); !strings.Contains(message, "corrupted metadata") {
+		t.Errorf("expected the storage error detail in %v", records[0])
+	}
+}
+
+func TestRequestLoggingRedactsCredentials(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+	r := router.SetupRouterWithStorage(fs)
+	logs := captureLogs(t, "debug", "json")
+
+	req := httptest.NewRequest("GET", "/test-bucket/", nil)
+	signRequest(req)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	presigned, err := auth.PresignURL("GET", "http://localhost:9090/test-bucket/", "accessuser", "accesspassword", "us-east-1", time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("could not presign URL: %v", err)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", presigned, nil))
+
+	received := logRecords(t, logs, "request received")
+	if len(received) != 2 {
+		t.Fatalf("expected two request records, got %d", len(received))
+	}
+	if authorization, _ := received[0]["authorization"].(string); !strings.HasPrefix(authorization, auth.Algorithm) || !strings.HasSuffix(authorization, "Signature=REDACTED") {
+		t.Errorf("expected a redacted Authorization header, got %q", authorization)
+	}
+	if uri, _ := received[1]["uri"].(string); !strings.Contains(uri, "X-Amz-Signature=REDACTED") || !strings.Contains(uri, "X-Amz-Credential=REDACTED") {
+		t.Errorf("expected a redacted presigned URI, got %q", uri)
+	}
+
+	signature := strings.TrimPrefix(req.Header.Get("Authorization")[strings.Index(req.Header.Get("Authorization"), "Signature="):], "Signature=")
+	if strings.Contains(logs.String(), signature) {
+		t.Errorf("expected the request signature to stay out of the logs")
+	}
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/metrics"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Setenv("METRICS_TOKEN", "scrape-token")
+	fs := newTestFileStorage(t, "test-bucket")
+	r := router.SetupRouterWithStorage(fs)
+
+	serve := func(method, target string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewReader(body))
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	content := []byte("metrics content")
+	if rr := serve("PUT", "/test-bucket/dir/object.txt", content); rr.Code != http.StatusOK {
+		t.Fatalf("expected PUT to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := serve("GET", "/test-bucket/dir/object.txt", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected GET to succeed, got %d", rr.Code)
+	}
+	if rr := serve("GET", "/test-bucket/missing.txt", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected GET of a missing object to fail, got %d", rr.Code)
+	}
+
+	// The endpoint is served with the bearer token instead of SigV4 authentication
+	scrape := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	for _, authorization := range []string{"", "Bearer wrong-token", "scrape-token"} {
+		if rr := scrape(authorization); rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+			t.Errorf("expected /metrics with Authorization %q to be denied, got %d (%s)", authorization, rr.Code, rr.Body.String())
+		}
+	}
+	rr := scrape("Bearer scrape-token")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected /metrics to succeed with the bearer token, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("expected the Prometheus text format, got Content-Type %q", contentType)
+	}
+
+	for _, line := range []string{
+		`s3_requests_total{operation="PutObject",status="200"} 1`,
+		`s3_requests_total{operation="GetObject",status="200"} 1`,
+		`s3_requests_total{operation="GetObject",status="404"} 1`,
+		`s3_request_duration_seconds_count{operation="GetObject",status="200"} 1`,
+		`s3_request_duration_seconds_bucket{operation="PutObject",status="200",le="+Inf"} 1`,
+		`s3_received_bytes_total{operation="PutObject"} 15`,
+		`s3_sent_bytes_total{operation="GetObject"} `,
+		`s3_requests_in_flight 1`,
+		`s3_storage_operations_total{method="AddObject"} 1`,
+		`s3_storage_errors_total{method="GetObject",code="NoSuchKey"} 1`,
+		`s3_bucket_objects{bucket="test-bucket"} 1`,
+		`s3_bucket_bytes{bucket="test-bucket"} 15`,
+		`# TYPE s3_request_duration_seconds histogram`,
+	} {
+		if !strings.Contains(rr.Body.String(), line) {
+			t.Errorf("expected the metrics to contain %q", line)
+		}
+	}
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	reg := metrics.NewRegistry()
+	mockStorage := &MockStorage{
+		DeleteBucketFunc: func(bucketName string) error {
+			return storage.ErrBucketNotEmpty
+		},
+	}
+	s := reg.InstrumentStorage(mockStorage)
+
+	if err := s.DeleteBucket("bucket"); err != storage.ErrBucketNotEmpty {
+		t.Fatalf("expected the instrumented storage to return the backend error, got %v", err)
+	}
+	if reg.StorageCalls.Value("DeleteBucket") != 1 || reg.StorageErrors.Value("DeleteBucket", "BucketNotEmpty") != 1 {
+		t.Errorf("expected one DeleteBucket call and one BucketNotEmpty error")
+	}
+
+	reg.RequestDuration.Observe(0.003, "GetObject", "200")
+	reg.RequestDuration.Observe(0.3, "GetObject", "200")
+	reg.RequestDuration.Observe(30, "GetObject", "200")
+
+	var out bytes.Buffer
+	if err := reg.Write(&out); err != nil {
+		t.Fatalf("could not write metrics: %v", err)
+	}
+	for _, line := range []string{
+		`s3_request_duration_seconds_bucket{operation="GetObject",status="200",le="0.005"} 1`,
+		`s3_request_duration_seconds_bucket{operation="GetObject",status="200",le="0.5"} 2`,
+		`s3_request_duration_seconds_bucket{operation="GetObject",status="200",le="10"} 2`,
+		`s3_request_duration_seconds_bucket{operation="GetObject",status="200",le="+Inf"} 3`,
+		`s3_request_duration_seconds_sum{operation="GetObject",status="200"} 30.303`,
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("expected the metrics to contain %q, got:\n%s", line, out.String())
+		}
+	}
+}
+
+// Bucket usage walks the storage, so it is computed at most once per TTL whatever the scrape rate
+func TestMetricsBucketUsageCached(t *testing.T) {
+	walks := 0
+	mockStorage := &MockStorage{
+		ListBucketsFunc: func() []string {
+			return []string{"bucket"}
+		},
+		BucketUsageFunc: func(bucketName string) (dto.BucketUsage, error) {
+			walks++
+			return dto.BucketUsage{Objects: 2, Bytes: 10}, nil
+		},
+	}
+	reg := metrics.NewRegistry()
+	reg.WatchBuckets(mockStorage)
+
+	for i := 0; i < 3; i++ {
+		var out bytes.Buffer
+		if err := reg.Write(&out); err != nil {
+			t.Fatalf("could not write metrics: %v", err)
+		}
+		if !strings.Contains(out.String(), `s3_bucket_objects{bucket="bucket"} 2`) {
+			t.Errorf("expected the cached bucket usage, got:\n%s", out.String())
+		}
+	}
+	if walks != 1 {
+		t.Errorf("expected the bucket to be walked once, got %d walks", walks)
+	}
+}
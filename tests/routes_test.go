@@ -2,25 +2,58 @@ package tests
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
 	"encoding/xml"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 	"github.com/gorilla/mux"
+	"my-s3-clone/auth"
 	"my-s3-clone/handlers"
+	"my-s3-clone/middleware"
 	"my-s3-clone/router"
 	"my-s3-clone/dto"
+	"my-s3-clone/storage"
 	"io"
+	"strings"
 	"time"
 	"fmt"
+	"sync"
 )
 
+// nopReadSeekCloser wraps a bytes.Reader so it satisfies io.ReadSeekCloser
+type nopReadSeekCloser struct {
+	*bytes.Reader
+}
+
+func (nopReadSeekCloser) Close() error { return nil }
+
+// signRequest signs a request with SigV4 using the default credentials of the server
+func signRequest(req *http.Request) {
+	auth.SignRequest(req, "accessuser", "accesspassword", "us-east-1", time.Now())
+}
+
+// S3 error code of an XML error response
+func errorCode(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var errorResponse dto.ErrorResponse
+	if err := xml.Unmarshal(rr.Body.Bytes(), &errorResponse); err != nil {
+		t.Fatalf("expected an XML error body but got %q: %v", rr.Body.String(), err)
+	}
+	return errorResponse.Code
+}
+
 // Mock implementation of FileInfo 
 type MockFileInfo struct {
 	name    string
 	size    int64
 	modTime time.Time
+	etag    string
+	meta    dto.ObjectMetadata
 }
 
 func (m MockFileInfo) Name() string       { return m.name }
@@ -29,26 +62,45 @@ func (m MockFileInfo) Mode() os.FileMode  { return 0644 }
 func (m MockFileInfo) ModTime() time.Time { return m.modTime }
 func (m MockFileInfo) IsDir() bool        { return false }
 func (m MockFileInfo) Sys() interface{}   { return nil }
+func (m MockFileInfo) ETag() string       { return m.etag }
+func (m MockFileInfo) Metadata() dto.ObjectMetadata { return m.meta }
 
 // MockStorage is a mock implementation of the Storage interface
 type MockStorage struct {
-	AddObjectFunc         func(bucketName, objectName string, data io.Reader, contentSha256 string) error
+	AddObjectFunc         func(bucketName, objectName string, data io.Reader, contentSha256 string, meta dto.ObjectMetadata) (string, error)
+	CopyObjectFunc        func(srcBucket, srcObject, dstBucket, dstObject string, meta *dto.ObjectMetadata) (dto.FileInfo, error)
 	DeleteObjectFunc      func(bucketName, objectName string) error
 	CheckBucketExistsFunc func(bucketName string) (bool, error)
-	CheckObjectExistFunc  func(bucketName, objectName string) (bool, time.Time, int64, error)
+	CheckObjectExistFunc  func(bucketName, objectName string) (bool, dto.FileInfo, error)
 	DeleteBucketFunc      func(bucketName string) error
-	GetObjectFunc         func(bucketName, objectName string) ([]byte, dto.FileInfo, error)
+	GetObjectFunc         func(bucketName, objectName string) (io.ReadSeekCloser, dto.FileInfo, error)
 	ListBucketsFunc       func() []string
-	ListObjectsFunc       func(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error)
-	CreateBucketFunc      func(bucketName string) error
+	ListObjectsFunc       func(bucketName, prefix, marker, delimiter string, maxKeys int) (dto.ListObjectsResponse, error)
+	CreateBucketFunc      func(bucketName string, meta dto.BucketMetadata) error
+	GetBucketMetadataFunc func(bucketName string) (dto.BucketMetadata, error)
+	BucketUsageFunc       func(bucketName string) (dto.BucketUsage, error)
+
+	CreateMultipartUploadFunc   func(bucketName, objectName string, meta dto.ObjectMetadata) (string, error)
+	UploadPartFunc              func(bucketName, objectName, uploadID string, partNumber int, data io.Reader, contentSha256 string) (string, error)
+	CompleteMultipartUploadFunc func(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (string, error)
+	AbortMultipartUploadFunc    func(bucketName, objectName, uploadID string) error
+	ListPartsFunc               func(bucketName, objectName, uploadID string) ([]dto.Part, error)
+	ListMultipartUploadsFunc    func(bucketName string) ([]dto.MultipartUpload, error)
 }
 
 // Implementations of the Storage interface using the mock functions
-func (m *MockStorage) AddObject(bucketName, objectName string, data io.Reader, contentSha256 string) error {
+func (m *MockStorage) AddObject(bucketName, objectName string, data io.Reader, contentSha256 string, meta dto.ObjectMetadata) (string, error) {
 	if m.AddObjectFunc != nil {
-		return m.AddObjectFunc(bucketName, objectName, data, contentSha256)
+		return m.AddObjectFunc(bucketName, objectName, data, contentSha256, meta)
 	}
-	return nil
+	return `"d41d8cd98f00b204e9800998ecf8427e"`, nil
+}
+
+func (m *MockStorage) CopyObject(srcBucket, srcObject, dstBucket, dstObject string, meta *dto.ObjectMetadata) (dto.FileInfo, error) {
+	if m.CopyObjectFunc != nil {
+		return m.CopyObjectFunc(srcBucket, srcObject, dstBucket, dstObject, meta)
+	}
+	return nil, storage.ErrObjectNotFound
 }
 
 func (m *MockStorage) DeleteObject(bucketName, objectName string) error {
@@ -65,11 +117,11 @@ func (m *MockStorage) CheckBucketExists(bucketName string) (bool, error) {
 	return false, nil
 }
 
-func (m *MockStorage) CheckObjectExist(bucketName, objectName string) (bool, time.Time, int64, error) {
+func (m *MockStorage) CheckObjectExist(bucketName, objectName string) (bool, dto.FileInfo, error) {
 	if m.CheckObjectExistFunc != nil {
 		return m.CheckObjectExistFunc(bucketName, objectName)
 	}
-	return false, time.Time{}, 0, nil
+	return false, nil, nil
 }
 
 func (m *MockStorage) DeleteBucket(bucketName string) error {
@@ -79,11 +131,11 @@ func (m *MockStorage) DeleteBucket(bucketName string) error {
 	return nil
 }
 
-func (m *MockStorage) GetObject(bucketName, objectName string) ([]byte, dto.FileInfo, error) {
+func (m *MockStorage) GetObject(bucketName, objectName string) (io.ReadSeekCloser, dto.FileInfo, error) {
 	if m.GetObjectFunc != nil {
 		return m.GetObjectFunc(bucketName, objectName)
 	}
-	return nil, nil, os.ErrNotExist
+	return nil, nil, storage.ErrObjectNotFound
 }
 
 func (m *MockStorage) ListBuckets() []string {
@@ -93,21 +145,77 @@ func (m *MockStorage) ListBuckets() []string {
 	return []string{}
 }
 
-func (m *MockStorage) ListObjects(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+func (m *MockStorage) ListObjects(bucketName, prefix, marker, delimiter string, maxKeys int) (dto.ListObjectsResponse, error) {
 	if m.ListObjectsFunc != nil {
-		return m.ListObjectsFunc(bucketName, prefix, marker, maxKeys)
+		return m.ListObjectsFunc(bucketName, prefix, marker, delimiter, maxKeys)
 	}
 	return dto.ListObjectsResponse{}, nil
 }
 
 // Mock implementation of CreateBucket
-func (m *MockStorage) CreateBucket(bucketName string) error {
+func (m *MockStorage) CreateBucket(bucketName string, meta dto.BucketMetadata) error {
     if m.CreateBucketFunc != nil {
-        return m.CreateBucketFunc(bucketName)
+        return m.CreateBucketFunc(bucketName, meta)
     }
     return nil
 }
 
+func (m *MockStorage) GetBucketMetadata(bucketName string) (dto.BucketMetadata, error) {
+	if m.GetBucketMetadataFunc != nil {
+		return m.GetBucketMetadataFunc(bucketName)
+	}
+	return dto.BucketMetadata{}, nil
+}
+
+func (m *MockStorage) BucketUsage(bucketName string) (dto.BucketUsage, error) {
+	if m.BucketUsageFunc != nil {
+		return m.BucketUsageFunc(bucketName)
+	}
+	return dto.BucketUsage{}, nil
+}
+
+func (m *MockStorage) CreateMultipartUpload(bucketName, objectName string, meta dto.ObjectMetadata) (string, error) {
+	if m.CreateMultipartUploadFunc != nil {
+		return m.CreateMultipartUploadFunc(bucketName, objectName, meta)
+	}
+	return "", nil
+}
+
+func (m *MockStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader, contentSha256 string) (string, error) {
+	if m.UploadPartFunc != nil {
+		return m.UploadPartFunc(bucketName, objectName, uploadID, partNumber, data, contentSha256)
+	}
+	return "", nil
+}
+
+func (m *MockStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (string, error) {
+	if m.CompleteMultipartUploadFunc != nil {
+		return m.CompleteMultipartUploadFunc(bucketName, objectName, uploadID, parts)
+	}
+	return "", nil
+}
+
+func (m *MockStorage) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+	if m.AbortMultipartUploadFunc != nil {
+		return m.AbortMultipartUploadFunc(bucketName, objectName, uploadID)
+	}
+	return nil
+}
+
+func (m *MockStorage) ListParts(bucketName, objectName, uploadID string) ([]dto.Part, error) {
+	if m.ListPartsFunc != nil {
+		return m.ListPartsFunc(bucketName, objectName, uploadID)
+	}
+	return []dto.Part{}, nil
+}
+
+func (m *MockStorage) ListMultipartUploads(bucketName string) ([]dto.MultipartUpload, error) {
+	if m.ListMultipartUploadsFunc != nil {
+		return m.ListMultipartUploadsFunc(bucketName)
+	}
+	return []dto.MultipartUpload{}, nil
+}
+
 // Test for the /probe-bsign{suffix:.*} route
 func TestProbeBSignRoute(t *testing.T) {
 	r := router.SetupRouter()
@@ -141,10 +249,51 @@ func TestProbeBSignRoute(t *testing.T) {
 	}
 }
 
+// Test for DELETE /{bucketName}/{objectName}
+func TestHandleDeleteSingleObject(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+	r := router.SetupRouterWithStorage(fs)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if _, err := fs.AddObject("test-bucket", "logs/2024/app.log", strings.NewReader("log line"), "", dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not add object: %v", err)
+	}
+
+	// Deleting is idempotent: a missing key is also answered with 204
+	for i := 0; i < 2; i++ {
+		if rr := serve("DELETE", "/test-bucket/logs/2024/app.log"); rr.Code != http.StatusNoContent || rr.Body.Len() != 0 {
+			t.Errorf("attempt %d: expected 204 without body but got %d (%s)", i+1, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := serve("GET", "/test-bucket/logs/2024/app.log"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected the deleted object to be gone, got %d", rr.Code)
+	}
+
+	if rr := serve("DELETE", "/missing-bucket/key"); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchBucket" {
+		t.Errorf("expected NoSuchBucket for a missing bucket, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	// DELETE with an uploadId still aborts the multipart upload
+	if rr := serve("DELETE", "/test-bucket/key?uploadId=0123456789abcdef0123456789abcdef"); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchUpload" {
+		t.Errorf("expected NoSuchUpload for an unknown upload, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
 // Test for the /{bucketName}/?delete= (POST batch delete)
 func TestHandleDeleteObject(t *testing.T) {
 	// Mock storage
-	mockStorage := &MockStorage{}
+	mockStorage := &MockStorage{
+		CheckBucketExistsFunc: func(bucketName string) (bool, error) {
+			return bucketName == "bucketName", nil
+		},
+	}
 
 	// Initialize the router with mock storage
 	r := router.SetupRouterWithStorage(mockStorage) // Assuming you have a way to inject storage into the router
@@ -171,6 +320,7 @@ func TestHandleDeleteObject(t *testing.T) {
 	req.Header.Set("Content-Type", "application/xml")
 
 	// Create a recorder to capture the response
+	signRequest(req)
 	rr := httptest.NewRecorder()
 
 	// Call the router with the simulated request
@@ -201,23 +351,139 @@ func TestHandleDeleteObject(t *testing.T) {
 	}
 }
 
+func TestBatchDeleteResults(t *testing.T) {
+	var mu sync.Mutex
+	deleted := make(map[string]bool)
+	mockStorage := &MockStorage{
+		CheckBucketExistsFunc: func(bucketName string) (bool, error) {
+			return bucketName == "test-bucket", nil
+		},
+		DeleteObjectFunc: func(bucketName, objectName string) error {
+			switch objectName {
+			case "missing.txt":
+				return storage.ErrObjectNotFound
+			case "":
+				return storage.ErrInvalidName
+			case "broken.txt":
+				return errors.New("disk failure")
+			}
+			mu.Lock()
+			deleted[objectName] = true
+			mu.Unlock()
+			return nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	batchDelete := func(bucket string, quiet bool, keys []string, contentMD5 string) *httptest.ResponseRecorder {
+		deleteReq := dto.DeleteObjectRequest{Quiet: quiet}
+		for _, key := range keys {
+			deleteReq.Objects = append(deleteReq.Objects, dto.ObjectToDelete{Key: key})
+		}
+		body, err := xml.Marshal(deleteReq)
+		if err != nil {
+			t.Fatalf("could not marshal request: %v", err)
+		}
+		req := httptest.NewRequest("POST", "/"+bucket+"/?delete", bytes.NewReader(body))
+		switch contentMD5 {
+		case "valid":
+			sum := md5.Sum(body)
+			req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		case "":
+		default:
+			req.Header.Set("Content-MD5", contentMD5)
+		}
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) dto.DeleteResult {
+		t.Helper()
+		var result dto.DeleteResult
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200 but got %d (%s)", rr.Code, rr.Body.String())
+		}
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("could not decode DeleteResult: %v", err)
+		}
+		return result
+	}
+
+	// Missing keys count as deleted, other failures are reported per key
+	result := decode(batchDelete("test-bucket", false, []string{"a.txt", "missing.txt", "", "broken.txt", "b.txt"}, "valid"))
+	var deletedKeys []string
+	for _, d := range result.DeletedResult {
+		deletedKeys = append(deletedKeys, d.Key)
+	}
+	if strings.Join(deletedKeys, ",") != "a.txt,missing.txt,b.txt" {
+		t.Errorf("unexpected deleted keys %q", deletedKeys)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Key != "" || result.Errors[0].Code != "InvalidArgument" ||
+		result.Errors[1].Key != "broken.txt" || result.Errors[1].Code != "InternalError" {
+		t.Errorf("unexpected errors %+v", result.Errors)
+	}
+
+	// Quiet mode only lists errors
+	result = decode(batchDelete("test-bucket", true, []string{"c.txt", "broken.txt"}, ""))
+	if len(result.DeletedResult) != 0 || len(result.Errors) != 1 || result.Errors[0].Key != "broken.txt" {
+		t.Errorf("unexpected quiet result %+v", result)
+	}
+
+	// Large batches are deleted in parallel, results keep the request order
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("bulk/%04d", i)
+	}
+	result = decode(batchDelete("test-bucket", false, keys, "valid"))
+	if len(result.DeletedResult) != len(keys) || len(deleted) != len(keys)+3 {
+		t.Fatalf("expected %d deletions, got %d results and %d calls", len(keys), len(result.DeletedResult), len(deleted))
+	}
+	for i, d := range result.DeletedResult {
+		if d.Key != keys[i] {
+			t.Fatalf("expected result %d to be %s but got %s", i, keys[i], d.Key)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		bucket       string
+		keys         []string
+		contentMD5   string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"too many keys", "test-bucket", append(keys, "one-more"), "", http.StatusBadRequest, "MalformedXML"},
+		{"no keys", "test-bucket", nil, "", http.StatusBadRequest, "MalformedXML"},
+		{"wrong Content-MD5", "test-bucket", []string{"a.txt"}, base64.StdEncoding.EncodeToString(make([]byte, md5.Size)), http.StatusBadRequest, "BadDigest"},
+		{"malformed Content-MD5", "test-bucket", []string{"a.txt"}, "not-base64", http.StatusBadRequest, "InvalidDigest"},
+		{"missing bucket", "missing-bucket", []string{"a.txt"}, "", http.StatusNotFound, "NoSuchBucket"},
+	}
+	for _, tt := range tests {
+		rr := batchDelete(tt.bucket, false, tt.keys, tt.contentMD5)
+		if rr.Code != tt.expectedCode || errorCode(t, rr) != tt.expectedErr {
+			t.Errorf("%s: expected %d %s but got %d (%s)", tt.name, tt.expectedCode, tt.expectedErr, rr.Code, rr.Body.String())
+		}
+	}
+}
+
 // Test for the /{bucketName}/{objectName} (POST/PUT) route
 func TestHandleAddObject(t *testing.T) {
 	// Create a new instance of the mock storage
 	mockStorage := &MockStorage{
-		AddObjectFunc: func(bucketName, objectName string, data io.Reader, contentSha256 string) error {
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, contentSha256 string, meta dto.ObjectMetadata) (string, error) {
 			if bucketName == "test-bucket" && objectName == "test-object" {
 				// Simulate successful upload, reading the content from the reader
 				buf := new(bytes.Buffer)
 				if _, err := buf.ReadFrom(data); err != nil {
-					return err
+					return "", err
 				}
 				if buf.String() != "file content" {
-					return fmt.Errorf("unexpected file content: %s", buf.String())
+					return "", fmt.Errorf("unexpected file content: %s", buf.String())
 				}
-				return nil
+				return `"d10b4c3ff123b26dc068d43a8bef2d23"`, nil
 			}
-			return os.ErrNotExist // Simulate failure
+			return "", storage.ErrBucketNotFound // Simulate failure
 		},
 		CheckBucketExistsFunc: func(bucketName string) (bool, error) {
 			if bucketName == "test-bucket" {
@@ -225,16 +491,17 @@ func TestHandleAddObject(t *testing.T) {
 			}
 			return false, nil
 		},
-		CheckObjectExistFunc: func(bucketName, objectName string) (bool, time.Time, int64, error) {
+		CheckObjectExistFunc: func(bucketName, objectName string) (bool, dto.FileInfo, error) {
 			if bucketName == "test-bucket" && objectName == "test-object" {
-				return true, time.Now(), 1234, nil
+				return true, MockFileInfo{name: objectName, size: 1234, modTime: time.Now()}, nil
 			}
-			return false, time.Time{}, 0, os.ErrNotExist
+			return false, nil, nil
 		},
 	}
 
-	// Initialize the router with the mock storage
+	// Initialize the router with the mock storage, request IDs are set by their middleware
 	r := mux.NewRouter()
+	r.Use(middleware.RequestIDMiddleware)
 	r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleAddObject(mockStorage)).Methods("POST", "PUT")
 
 	// Create a POST request to upload an object
@@ -270,8 +537,8 @@ func TestHandleAddObject(t *testing.T) {
 	}
 
 	// Validate the response headers
-	if rr.Header().Get("ETag") == "" {
-		t.Errorf("expected ETag header to be set")
+	if rr.Header().Get("ETag") != `"d10b4c3ff123b26dc068d43a8bef2d23"` {
+		t.Errorf("expected ETag returned by storage but got %q", rr.Header().Get("ETag"))
 	}
 	if rr.Header().Get("x-amz-id-2") == "" {
 		t.Errorf("expected x-amz-id-2 header to be set")
@@ -284,13 +551,13 @@ func TestHandleAddObject(t *testing.T) {
 func TestHandleCheckObjectExist(t *testing.T) {
 	// Create a new instance of the mock storage
 	mockStorage := &MockStorage{
-		CheckObjectExistFunc: func(bucketName, objectName string) (bool, time.Time, int64, error) {
+		CheckObjectExistFunc: func(bucketName, objectName string) (bool, dto.FileInfo, error) {
 			// Simulate that the object exists
 			if bucketName == "test-bucket" && objectName == "test-object" {
-				return true, time.Now(), 1234, nil
+				return true, MockFileInfo{name: objectName, size: 1234, modTime: time.Now(), etag: `"abc123"`}, nil
 			}
 			// Simulate that the object does not exist
-			return false, time.Time{}, 0, nil
+			return false, nil, nil
 		},
 	}
 
@@ -335,6 +602,9 @@ func TestHandleCheckObjectExist(t *testing.T) {
 			if rr.Header().Get("Content-Length") != "1234" {
 				t.Errorf("expected Content-Length to be 1234 but got %s", rr.Header().Get("Content-Length"))
 			}
+			if rr.Header().Get("ETag") != `"abc123"` {
+				t.Errorf("expected ETag to be \"abc123\" but got %s", rr.Header().Get("ETag"))
+			}
 		}
 	}
 }
@@ -342,7 +612,7 @@ func TestHandleCheckObjectExist(t *testing.T) {
 func TestHandleListObjects(t *testing.T) {
     // Create a new instance of the mock storage
     mockStorage := &MockStorage{
-        ListObjectsFunc: func(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+        ListObjectsFunc: func(bucketName, prefix, marker, delimiter string, maxKeys int) (dto.ListObjectsResponse, error) {
             // Simulate a response with some objects
             if marker == "object1.txt" {
                 // Simulate paginated response
@@ -444,6 +714,9 @@ func TestHandleGetBucket(t *testing.T) {
 			}
 			return false, nil
 		},
+		GetBucketMetadataFunc: func(bucketName string) (dto.BucketMetadata, error) {
+			return dto.BucketMetadata{Region: "us-east-1"}, nil
+		},
 	}
 
 	// Initialize the router with mock storage
@@ -458,7 +731,7 @@ func TestHandleGetBucket(t *testing.T) {
 	}{
 		{"existing-bucket", "", http.StatusOK, "Bucket 'existing-bucket' exists and is accessible."},
 		{"existing-bucket", "location", http.StatusOK, "<LocationConstraint>us-east-1</LocationConstraint>"},
-		{"nonexistent-bucket", "", http.StatusNotFound, "Bucket 'nonexistent-bucket' not found\n"},
+		{"nonexistent-bucket", "", http.StatusNotFound, "NoSuchBucket"},
 	}
 
 	for _, tt := range tests {
@@ -483,9 +756,13 @@ func TestHandleGetBucket(t *testing.T) {
 			t.Errorf("expected status %d but got %d for bucket: %s", tt.expectedCode, rr.Code, tt.bucketName)
 		}
 
-		// Check the response body
-		if rr.Body.String() != tt.expectedBody {
-			t.Errorf("expected body %q but got %q", tt.expectedBody, rr.Body.String())
+		// Check the response body, errors are compared by their S3 code
+		body := rr.Body.String()
+		if rr.Code >= http.StatusBadRequest {
+			body = errorCode(t, rr)
+		}
+		if body != tt.expectedBody {
+			t.Errorf("expected body %q but got %q", tt.expectedBody, body)
 		}
 	}
 }
@@ -493,7 +770,7 @@ func TestHandleGetBucket(t *testing.T) {
 func TestHandleCreateBucket(t *testing.T) {
 	// Mock storage
 	mockStorage := &MockStorage{
-		CreateBucketFunc: func(bucketName string) error {
+		CreateBucketFunc: func(bucketName string, meta dto.BucketMetadata) error {
 			if bucketName == "test-bucket" {
 				// Simulate successful bucket creation
 				return nil
@@ -513,7 +790,12 @@ func TestHandleCreateBucket(t *testing.T) {
 		expectedBody string
 	}{
 		{"test-bucket", http.StatusOK, ""},         
-		{"fail-bucket", http.StatusInternalServerError, "failed to create bucket\n"}, 
+		{"fail-bucket", http.StatusInternalServerError, "InternalError"}, 
+		// Names breaking the S3 naming rules never reach the storage
+		{"Invalid_Bucket", http.StatusBadRequest, "InvalidBucketName"},
+		{"ab", http.StatusBadRequest, "InvalidBucketName"},
+		{"10.0.0.1", http.StatusBadRequest, "InvalidBucketName"},
+		{".minio.sys", http.StatusBadRequest, "InvalidBucketName"},
 	}
 
 	for _, tt := range tests {
@@ -547,8 +829,8 @@ func TestHandleCreateBucket(t *testing.T) {
 			if actualResponse != xmlResponse {
 				t.Errorf("Expected XML response to be: %s, but got: %s", xmlResponse, actualResponse)
 			}
-		} else if rr.Body.String() != tt.expectedBody {
-			t.Errorf("expected body %q but got %q", tt.expectedBody, rr.Body.String())
+		} else if code := errorCode(t, rr); code != tt.expectedBody {
+			t.Errorf("expected error code %q but got %q", tt.expectedBody, code)
 		}
 	}
 }
@@ -584,7 +866,7 @@ func TestHandleDeleteBucket(t *testing.T) {
 		{
 			bucketName:   "fail-bucket",
 			expectedCode: http.StatusInternalServerError,
-			expectedBody: "Failed to delete bucket\n",
+			expectedBody: "InternalError",
 		},
 	}
 
@@ -607,13 +889,87 @@ func TestHandleDeleteBucket(t *testing.T) {
 			t.Errorf("expected status %d but got %d for bucket: %s", tt.expectedCode, rr.Code, tt.bucketName)
 		}
 
-		// Check the response body
-		if rr.Body.String() != tt.expectedBody {
-			t.Errorf("expected body %q but got %q for bucket: %s", tt.expectedBody, rr.Body.String(), tt.bucketName)
+		// Check the response body, errors are compared by their S3 code
+		body := rr.Body.String()
+		if rr.Code >= http.StatusBadRequest {
+			body = errorCode(t, rr)
+		}
+		if body != tt.expectedBody {
+			t.Errorf("expected body %q but got %q for bucket: %s", tt.expectedBody, body, tt.bucketName)
 		}
 	}
 }
 
+// Non-empty buckets are only deleted through the admin force-delete, which runs in the background
+func TestForceDeleteBucket(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+	for i := 0; i < 25; i++ {
+		if _, err := fs.AddObject("test-bucket", fmt.Sprintf("dir/key-%02d", i), strings.NewReader("data"), "", dto.ObjectMetadata{}); err != nil {
+			t.Fatalf("could not add object: %v", err)
+		}
+	}
+
+	serve := func(r *mux.Router, method, target string, force bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if force {
+			req.Header.Set(handlers.ForceDeleteHeader, "true")
+		}
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Without S3_ADMIN_ACCESS_KEYS nobody may force a deletion
+	r := router.SetupRouterWithStorage(fs)
+	if rr := serve(r, "DELETE", "/test-bucket/", false); rr.Code != http.StatusConflict || errorCode(t, rr) != "BucketNotEmpty" {
+		t.Fatalf("expected BucketNotEmpty, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := serve(r, "DELETE", "/test-bucket/", true); rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+		t.Fatalf("expected AccessDenied for a non-admin access key, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	t.Setenv("S3_ADMIN_ACCESS_KEYS", "other, accessuser")
+	r = router.SetupRouterWithStorage(fs)
+	if rr := serve(r, "GET", "/_admin/bucket-deletions/test-bucket", false); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected no deletion status before the deletion, got %d", rr.Code)
+	}
+	if rr := serve(r, "DELETE", "/missing-bucket/", true); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchBucket" {
+		t.Fatalf("expected NoSuchBucket, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	rr := serve(r, "DELETE", "/test-bucket/", true)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected the forced deletion to be accepted, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if location := rr.Header().Get("Location"); location != "/_admin/bucket-deletions/test-bucket" {
+		t.Errorf("expected the status location in the response, got %q", location)
+	}
+
+	var status dto.BucketDeletionStatus
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rr := serve(r, "GET", "/_admin/bucket-deletions/test-bucket", false)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected the deletion status, got %d (%s)", rr.Code, rr.Body.String())
+		}
+		if err := xml.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+			t.Fatalf("could not decode the deletion status: %v", err)
+		}
+		if status.Status != handlers.BucketDeletionInProgress || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status.Status != handlers.BucketDeletionCompleted || status.ObjectsTotal != 25 || status.ObjectsDeleted != 25 || status.EndTime == nil {
+		t.Errorf("expected a completed deletion of 25 objects, got %+v", status)
+	}
+	if exists, err := fs.CheckBucketExists("test-bucket"); exists || err != nil {
+		t.Errorf("expected the bucket to be deleted, got %v, %v", exists, err)
+	}
+}
+
 func TestHandleListBuckets(t *testing.T) {
 	// Set up the mock storage
 	mockStorage := &MockStorage{
@@ -667,4 +1023,798 @@ func TestHandleListBuckets(t *testing.T) {
 	if contentType := rr.Header().Get("Content-Type"); contentType != "application/xml" {
 		t.Errorf("expected content type application/xml but got %q", contentType)
 	}
-}
\ No newline at end of file
+}
+
+// Bucket metadata given at creation is served by the list and bucket configuration routes
+func TestBucketConfiguration(t *testing.T) {
+	fs := storage.NewFileStorage(t.TempDir())
+	r := router.SetupRouterWithStorage(fs)
+
+	serve := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	config := `<CreateBucketConfiguration><LocationConstraint>eu-west-3</LocationConstraint></CreateBucketConfiguration>`
+	if rr := serve("PUT", "/eu-bucket/", config, map[string]string{"x-amz-bucket-object-lock-enabled": "false"}); rr.Code != http.StatusOK {
+		t.Fatalf("could not create bucket: %d (%s)", rr.Code, rr.Body.String())
+	}
+	// Object lock and versioning are not enforced: asking for them must fail rather than be silently ignored
+	if rr := serve("PUT", "/locked-bucket/", "", map[string]string{"x-amz-bucket-object-lock-enabled": "true"}); rr.Code != http.StatusNotImplemented || errorCode(t, rr) != "NotImplemented" {
+		t.Errorf("expected NotImplemented for object lock, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	if exists, _ := fs.CheckBucketExists("locked-bucket"); exists {
+		t.Errorf("expected no bucket to be created when object lock is requested")
+	}
+	if rr := serve("PUT", "/plain-bucket/", "", nil); rr.Code != http.StatusOK {
+		t.Fatalf("could not create bucket: %d (%s)", rr.Code, rr.Body.String())
+	}
+	if rr := serve("PUT", "/malformed-bucket/", "<CreateBucketConfiguration>", nil); rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedXML" {
+		t.Errorf("expected MalformedXML, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	meta, err := fs.GetBucketMetadata("eu-bucket")
+	if err != nil {
+		t.Fatalf("could not get bucket metadata: %v", err)
+	}
+	if meta.Region != "eu-west-3" || meta.Owner != "accessuser" {
+		t.Errorf("unexpected bucket metadata %+v", meta)
+	}
+
+	// The creation dates are the stored ones, identical from one listing to the next
+	rr := serve("GET", "/", "", nil)
+	var list dto.ListAllMyBucketsResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("could not decode bucket list: %v", err)
+	}
+	if len(list.Buckets) != 2 || list.Buckets[0].Name != "eu-bucket" || !list.Buckets[0].CreationDate.Equal(meta.CreationDate) || list.Buckets[0].BucketRegion != "eu-west-3" {
+		t.Errorf("expected the stored creation date %v and region, got %+v", meta.CreationDate, list.Buckets)
+	}
+
+	for _, tt := range []struct {
+		target       string
+		expectedCode int
+		expectedBody string
+	}{
+		{"/eu-bucket/?location", http.StatusOK, `<LocationConstraint>eu-west-3</LocationConstraint>`},
+		{"/plain-bucket/?location", http.StatusOK, `<LocationConstraint>us-east-1</LocationConstraint>`},
+		{"/eu-bucket/?object-lock", http.StatusNotFound, "ObjectLockConfigurationNotFoundError"},
+		{"/plain-bucket/?object-lock", http.StatusNotFound, "ObjectLockConfigurationNotFoundError"},
+		{"/eu-bucket/?versioning", http.StatusOK, `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></VersioningConfiguration>`},
+		{"/missing-bucket/?object-lock", http.StatusNotFound, "NoSuchBucket"},
+		{"/plain-bucket/?versioning", http.StatusOK, `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></VersioningConfiguration>`},
+		{"/missing-bucket/?location", http.StatusNotFound, "NoSuchBucket"},
+	} {
+		rr := serve("GET", tt.target, "", nil)
+		if rr.Code != tt.expectedCode {
+			t.Errorf("GET %s: expected status %d but got %d (%s)", tt.target, tt.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+		body := strings.TrimSpace(rr.Body.String())
+		if rr.Code >= http.StatusBadRequest {
+			body = errorCode(t, rr)
+		}
+		if body != tt.expectedBody {
+			t.Errorf("GET %s: expected %q but got %q", tt.target, tt.expectedBody, body)
+		}
+	}
+}
+
+func TestHandleDownloadObject(t *testing.T) {
+	content := []byte("streamed file content")
+	modTime := time.Date(2024, 9, 16, 10, 12, 24, 0, time.UTC)
+
+	// Mock storage serving a single object
+	mockStorage := &MockStorage{
+		GetObjectFunc: func(bucketName, objectName string) (io.ReadSeekCloser, dto.FileInfo, error) {
+			if bucketName == "test-bucket" && objectName == "test-object" {
+				info := MockFileInfo{name: objectName, size: int64(len(content)), modTime: modTime}
+				return nopReadSeekCloser{bytes.NewReader(content)}, info, nil
+			}
+			return nil, nil, storage.ErrObjectNotFound
+		},
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleDownloadObject(mockStorage)).Methods("GET")
+
+	tests := []struct {
+		objectName   string
+		expectedCode int
+	}{
+		{"test-object", http.StatusOK},
+		{"nonexistent-object", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", "/test-bucket/"+tt.objectName, nil)
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("expected status %d but got %d for object: %s", tt.expectedCode, rr.Code, tt.objectName)
+		}
+
+		if tt.expectedCode == http.StatusOK {
+			if rr.Body.String() != string(content) {
+				t.Errorf("expected body %q but got %q", content, rr.Body.String())
+			}
+			if rr.Header().Get("Content-Length") != fmt.Sprintf("%d", len(content)) {
+				t.Errorf("expected Content-Length %d but got %s", len(content), rr.Header().Get("Content-Length"))
+			}
+			if rr.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+				t.Errorf("unexpected Last-Modified header: %s", rr.Header().Get("Last-Modified"))
+			}
+		}
+	}
+}
+
+func TestHandleDownloadObjectRange(t *testing.T) {
+	content := []byte("0123456789")
+
+	mockStorage := &MockStorage{
+		GetObjectFunc: func(bucketName, objectName string) (io.ReadSeekCloser, dto.FileInfo, error) {
+			info := MockFileInfo{name: objectName, size: int64(len(content)), modTime: time.Now()}
+			return nopReadSeekCloser{bytes.NewReader(content)}, info, nil
+		},
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleDownloadObject(mockStorage)).Methods("GET")
+
+	tests := []struct {
+		rangeHeader   string
+		expectedCode  int
+		expectedBody  string
+		expectedRange string
+	}{
+		{"", http.StatusOK, "0123456789", ""},
+		{"bytes=2-5", http.StatusPartialContent, "2345", "bytes 2-5/10"},
+		{"bytes=7-", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=-3", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=-20", http.StatusPartialContent, "0123456789", "bytes 0-9/10"},
+		{"bytes=8-100", http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"bytes=10-", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"bytes=-0", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"bytes=0-1,4-5", http.StatusOK, "0123456789", ""},
+		{"items=0-1", http.StatusOK, "0123456789", ""},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", "/test-bucket/test-object", nil)
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+		if tt.rangeHeader != "" {
+			req.Header.Set("Range", tt.rangeHeader)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("expected status %d but got %d for range %q", tt.expectedCode, rr.Code, tt.rangeHeader)
+		}
+		if rr.Header().Get("Content-Range") != tt.expectedRange {
+			t.Errorf("expected Content-Range %q but got %q for range %q", tt.expectedRange, rr.Header().Get("Content-Range"), tt.rangeHeader)
+		}
+		if rr.Header().Get("Accept-Ranges") != "bytes" {
+			t.Errorf("expected Accept-Ranges bytes for range %q", tt.rangeHeader)
+		}
+		if tt.expectedCode != http.StatusRequestedRangeNotSatisfiable && rr.Body.String() != tt.expectedBody {
+			t.Errorf("expected body %q but got %q for range %q", tt.expectedBody, rr.Body.String(), tt.rangeHeader)
+		}
+	}
+}
+
+// Object keys containing slashes must reach the object handlers untouched
+func TestObjectMetadataHeaders(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+	r := router.SetupRouterWithStorage(fs)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	req := httptest.NewRequest("PUT", "/test-bucket/report.html", strings.NewReader("<html></html>"))
+	req.Header.Set("Content-Type", "text/html; charset=utf-8")
+	req.Header.Set("Cache-Control", "max-age=3600")
+	req.Header.Set("Content-Disposition", `inline; filename="report.html"`)
+	req.Header.Set("X-Amz-Meta-Author", "alice")
+	req.Header.Set("x-amz-meta-project-id", "42")
+	if rr := serve(req); rr.Code != http.StatusOK {
+		t.Fatalf("expected PUT to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+
+	expected := map[string]string{
+		"Content-Type":          "text/html; charset=utf-8",
+		"Cache-Control":         "max-age=3600",
+		"Content-Disposition":   `inline; filename="report.html"`,
+		"x-amz-meta-author":     "alice",
+		"x-amz-meta-project-id": "42",
+	}
+	for _, method := range []string{"GET", "HEAD"} {
+		rr := serve(httptest.NewRequest(method, "/test-bucket/report.html", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected %s to succeed, got %d", method, rr.Code)
+		}
+		for name, value := range expected {
+			// User metadata is sent with lowercase names, as S3 does
+			if got := rr.Header()[name]; len(got) != 1 || got[0] != value {
+				t.Errorf("%s: expected header %s %q but got %q", method, name, value, got)
+			}
+		}
+		if rr.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: expected no Content-Encoding, got %q", method, rr.Header().Get("Content-Encoding"))
+		}
+	}
+
+	// Objects uploaded without metadata are served as inline binary content
+	if rr := serve(httptest.NewRequest("PUT", "/test-bucket/plain.bin", strings.NewReader("data"))); rr.Code != http.StatusOK {
+		t.Fatalf("expected PUT to succeed, got %d", rr.Code)
+	}
+	rr := serve(httptest.NewRequest("GET", "/test-bucket/plain.bin", nil))
+	if rr.Header().Get("Content-Type") != "application/octet-stream" || rr.Header().Get("Content-Disposition") != "" {
+		t.Errorf("unexpected default headers: Content-Type %q, Content-Disposition %q",
+			rr.Header().Get("Content-Type"), rr.Header().Get("Content-Disposition"))
+	}
+
+	// The aws-chunked transfer encoding is dropped, the content encodings are kept
+	req = httptest.NewRequest("PUT", "/test-bucket/archive.gz", strings.NewReader("data"))
+	req.Header.Set("Content-Encoding", "aws-chunked, gzip")
+	if rr := serve(req); rr.Code != http.StatusOK {
+		t.Fatalf("expected PUT to succeed, got %d", rr.Code)
+	}
+	if rr := serve(httptest.NewRequest("GET", "/test-bucket/archive.gz", nil)); rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("expected Content-Encoding gzip, got %q", rr.Header().Get("Content-Encoding"))
+	}
+
+	// User metadata is limited to 2 KB
+	req = httptest.NewRequest("PUT", "/test-bucket/large-metadata.txt", strings.NewReader("data"))
+	req.Header.Set("X-Amz-Meta-Notes", strings.Repeat("x", 2048))
+	if rr := serve(req); rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MetadataTooLarge" {
+		t.Errorf("expected MetadataTooLarge, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestCopyObject(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+	if err := fs.CreateBucket("other-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	r := router.SetupRouterWithStorage(fs)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	copyRequest := func(target, source string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", target, nil)
+		req.Header.Set("X-Amz-Copy-Source", source)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return serve(req)
+	}
+
+	req := httptest.NewRequest("PUT", "/test-bucket/photos/cat%20picture.jpg", strings.NewReader("image data"))
+	req.Header.Set("Content-Type", "image/jpeg")
+	req.Header.Set("X-Amz-Meta-Camera", "x100")
+	put := serve(req)
+	if put.Code != http.StatusOK {
+		t.Fatalf("expected PUT to succeed, got %d (%s)", put.Code, put.Body.String())
+	}
+	etag := put.Header().Get("ETag")
+
+	// COPY directive: content and metadata come from the source
+	rr := copyRequest("/other-bucket/copy.jpg", "/test-bucket/photos/cat%20picture.jpg", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected copy to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	var result dto.CopyObjectResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("could not decode CopyObjectResult: %v", err)
+	}
+	if result.ETag != etag || result.LastModified.IsZero() {
+		t.Errorf("expected ETag %s and a LastModified date, got %+v", etag, result)
+	}
+	get := serve(httptest.NewRequest("GET", "/other-bucket/copy.jpg", nil))
+	if get.Body.String() != "image data" || get.Header().Get("Content-Type") != "image/jpeg" || strings.Join(get.Header()["x-amz-meta-camera"], ",") != "x100" {
+		t.Errorf("unexpected copy %q with headers %v", get.Body.String(), get.Header())
+	}
+
+	// REPLACE directive: metadata comes from the request
+	rr = copyRequest("/other-bucket/replaced.jpg", "test-bucket/photos/cat%20picture.jpg", map[string]string{
+		"X-Amz-Metadata-Directive": "REPLACE",
+		"Content-Type":             "application/x-image",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected REPLACE copy to succeed, got %d (%s)", rr.Code, rr.Body.String())
+	}
+	head := serve(httptest.NewRequest("HEAD", "/other-bucket/replaced.jpg", nil))
+	if head.Header().Get("Content-Type") != "application/x-image" || len(head.Header()["x-amz-meta-camera"]) != 0 {
+		t.Errorf("expected replaced metadata, got headers %v", head.Header())
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name         string
+		source       string
+		headers      map[string]string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"if-match", "/test-bucket/photos/cat%20picture.jpg", map[string]string{"X-Amz-Copy-Source-If-Match": etag}, http.StatusOK, ""},
+		{"if-match mismatch", "/test-bucket/photos/cat%20picture.jpg", map[string]string{"X-Amz-Copy-Source-If-Match": `"0123"`}, http.StatusPreconditionFailed, "PreconditionFailed"},
+		{"if-none-match", "/test-bucket/photos/cat%20picture.jpg", map[string]string{"X-Amz-Copy-Source-If-None-Match": etag}, http.StatusPreconditionFailed, "PreconditionFailed"},
+		{"if-modified-since", "/test-bucket/photos/cat%20picture.jpg", map[string]string{"X-Amz-Copy-Source-If-Modified-Since": future}, http.StatusPreconditionFailed, "PreconditionFailed"},
+		{"if-unmodified-since", "/test-bucket/photos/cat%20picture.jpg", map[string]string{"X-Amz-Copy-Source-If-Unmodified-Since": past}, http.StatusPreconditionFailed, "PreconditionFailed"},
+		{"if-match wins over if-unmodified-since", "/test-bucket/photos/cat%20picture.jpg", map[string]string{"X-Amz-Copy-Source-If-Match": etag, "X-Amz-Copy-Source-If-Unmodified-Since": past}, http.StatusOK, ""},
+		{"missing source", "/test-bucket/missing.jpg", nil, http.StatusNotFound, "NoSuchKey"},
+		{"missing source bucket", "/missing-bucket/key", nil, http.StatusNotFound, "NoSuchBucket"},
+		{"malformed source", "test-bucket", nil, http.StatusBadRequest, "InvalidArgument"},
+		{"unknown directive", "/test-bucket/photos/cat%20picture.jpg", map[string]string{"X-Amz-Metadata-Directive": "MERGE"}, http.StatusBadRequest, "InvalidArgument"},
+	}
+	for _, tt := range tests {
+		rr := copyRequest("/other-bucket/conditional.jpg", tt.source, tt.headers)
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d (%s)", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if tt.expectedErr != "" && errorCode(t, rr) != tt.expectedErr {
+			t.Errorf("%s: expected error %s but got %s", tt.name, tt.expectedErr, errorCode(t, rr))
+		}
+	}
+
+	// Copying an object onto itself is only allowed when its metadata changes
+	if rr := copyRequest("/test-bucket/photos/cat%20picture.jpg", "/test-bucket/photos/cat%20picture.jpg", nil); rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidRequest" {
+		t.Errorf("expected a self-copy without REPLACE to be rejected, got %d (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestNestedObjectKeyRouting(t *testing.T) {
+	const nestedKey = "logs/2024/10/app.log"
+	var receivedKey string
+
+	mockStorage := &MockStorage{
+		GetObjectFunc: func(bucketName, objectName string) (io.ReadSeekCloser, dto.FileInfo, error) {
+			receivedKey = objectName
+			info := MockFileInfo{name: objectName, size: 2, modTime: time.Now()}
+			return nopReadSeekCloser{bytes.NewReader([]byte("ok"))}, info, nil
+		},
+		CheckObjectExistFunc: func(bucketName, objectName string) (bool, dto.FileInfo, error) {
+			receivedKey = objectName
+			return true, MockFileInfo{name: objectName, size: 2, modTime: time.Now()}, nil
+		},
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, contentSha256 string, meta dto.ObjectMetadata) (string, error) {
+			receivedKey = objectName
+			return `"etag"`, nil
+		},
+	}
+
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	for _, method := range []string{"GET", "HEAD", "PUT"} {
+		receivedKey = ""
+		req, err := http.NewRequest(method, "/test-bucket/"+nestedKey, bytes.NewBufferString("ok"))
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+		req.Header.Set("X-Amz-Decoded-Content-Length", "2")
+
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status %d but got %d for %s", http.StatusOK, rr.Code, method)
+		}
+		if receivedKey != nestedKey {
+			t.Errorf("expected key %q but storage received %q for %s", nestedKey, receivedKey, method)
+		}
+	}
+}
+
+// A key colliding with an existing object or folder is a client error, not an internal one
+func TestObjectKeyConflict(t *testing.T) {
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, contentSha256 string, meta dto.ObjectMetadata) (string, error) {
+			if objectName == "a/b" {
+				return "", storage.ErrParentIsObject
+			}
+			return "", storage.ErrObjectExistsAsDirectory
+		},
+	}
+
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	for key, expectedCode := range map[string]string{"a/b": "ParentIsObject", "folder": "ObjectExistsAsDirectory"} {
+		req, err := http.NewRequest("PUT", "/test-bucket/"+key, bytes.NewBufferString("ok"))
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+		req.Header.Set("X-Amz-Decoded-Content-Length", "2")
+
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("PUT %s: expected status %d but got %d", key, http.StatusConflict, rr.Code)
+		}
+		if code := errorCode(t, rr); code != expectedCode {
+			t.Errorf("PUT %s: expected error code %s but got %s", key, expectedCode, code)
+		}
+	}
+}
+
+func TestObjectKeyTooLong(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+	r := router.SetupRouterWithStorage(fs)
+
+	for _, key := range []string{strings.Repeat("k", 1025), "dir/" + strings.Repeat("k", 256)} {
+		req := httptest.NewRequest("PUT", "/test-bucket/"+key, bytes.NewBufferString("ok"))
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "KeyTooLongError" {
+			t.Errorf("PUT a %d-byte key: expected 400 KeyTooLongError but got %d (%s)", len(key), rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestHandleListObjectsDelimiter(t *testing.T) {
+	var receivedDelimiter, receivedPrefix string
+
+	mockStorage := &MockStorage{
+		ListObjectsFunc: func(bucketName, prefix, marker, delimiter string, maxKeys int) (dto.ListObjectsResponse, error) {
+			receivedDelimiter = delimiter
+			receivedPrefix = prefix
+			return dto.ListObjectsResponse{
+				Name:      bucketName,
+				Prefix:    prefix,
+				Delimiter: delimiter,
+				MaxKeys:   maxKeys,
+				Contents: []dto.Object{
+					{Key: "logs/readme.txt", LastModified: time.Now(), Size: 12},
+				},
+				CommonPrefixes: []dto.CommonPrefix{
+					{Prefix: "logs/2023/"},
+					{Prefix: "logs/2024/"},
+				},
+			}, nil
+		},
+	}
+
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	req, err := http.NewRequest("GET", "/test-bucket/?prefix=logs/&delimiter=/", nil)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+
+	signRequest(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+	if receivedDelimiter != "/" || receivedPrefix != "logs/" {
+		t.Errorf("expected storage to receive prefix %q and delimiter %q, got %q and %q", "logs/", "/", receivedPrefix, receivedDelimiter)
+	}
+
+	var listObjectsResponse dto.ListObjectsResponse
+	if err := xml.Unmarshal(rr.Body.Bytes(), &listObjectsResponse); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+
+	expectedPrefixes := []string{"logs/2023/", "logs/2024/"}
+	if len(listObjectsResponse.CommonPrefixes) != len(expectedPrefixes) {
+		t.Fatalf("expected %d common prefixes but got %d", len(expectedPrefixes), len(listObjectsResponse.CommonPrefixes))
+	}
+	for i, commonPrefix := range listObjectsResponse.CommonPrefixes {
+		if commonPrefix.Prefix != expectedPrefixes[i] {
+			t.Errorf("expected common prefix %s but got %s", expectedPrefixes[i], commonPrefix.Prefix)
+		}
+	}
+	if listObjectsResponse.Delimiter != "/" {
+		t.Errorf("expected delimiter / but got %q", listObjectsResponse.Delimiter)
+	}
+}
+
+func TestHandleListObjectsV2Pagination(t *testing.T) {
+	keys := []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"}
+
+	// Mock storage paging over a sorted key list, starting after the marker
+	mockStorage := &MockStorage{
+		ListObjectsFunc: func(bucketName, prefix, marker, delimiter string, maxKeys int) (dto.ListObjectsResponse, error) {
+			response := dto.ListObjectsResponse{Name: bucketName, Marker: marker, MaxKeys: maxKeys}
+			for _, key := range keys {
+				if key <= marker {
+					continue
+				}
+				if len(response.Contents) >= maxKeys {
+					response.IsTruncated = true
+					break
+				}
+				response.Contents = append(response.Contents, dto.Object{Key: key, LastModified: time.Now(), Size: 1})
+			}
+			return response, nil
+		},
+	}
+
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	var listed []string
+	token := ""
+	for page := 0; page < len(keys); page++ {
+		url := "/test-bucket/?list-type=2&max-keys=2&start-after=a.txt&fetch-owner=true"
+		if token != "" {
+			url += "&continuation-token=" + token
+		}
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d but got %d", http.StatusOK, rr.Code)
+		}
+
+		var result dto.ListObjectsV2Response
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Error unmarshaling response body: %v", err)
+		}
+
+		if result.KeyCount != len(result.Contents) {
+			t.Errorf("expected KeyCount %d but got %d", len(result.Contents), result.KeyCount)
+		}
+		for _, obj := range result.Contents {
+			if obj.Owner == nil {
+				t.Errorf("expected owner for key %s with fetch-owner=true", obj.Key)
+			}
+			listed = append(listed, obj.Key)
+		}
+
+		if !result.IsTruncated {
+			if result.NextContinuationToken != "" {
+				t.Errorf("expected no NextContinuationToken on last page")
+			}
+			break
+		}
+		if result.NextContinuationToken == "" {
+			t.Fatalf("expected NextContinuationToken on truncated page")
+		}
+		token = result.NextContinuationToken
+	}
+
+	expected := []string{"b.txt", "c.txt", "d.txt", "e.txt"}
+	if fmt.Sprint(listed) != fmt.Sprint(expected) {
+		t.Errorf("expected keys %v but got %v", expected, listed)
+	}
+
+	// An undecodable continuation token is rejected
+	req, _ := http.NewRequest("GET", "/test-bucket/?list-type=2&continuation-token=%25%25", nil)
+	signRequest(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for invalid token but got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+// Multipart requests are dispatched on their query parameters
+func TestMultipartUploadRoutes(t *testing.T) {
+	var called []string
+
+	mockStorage := &MockStorage{
+		CreateMultipartUploadFunc: func(bucketName, objectName string, meta dto.ObjectMetadata) (string, error) {
+			called = append(called, "create:"+objectName)
+			return "0123456789abcdef0123456789abcdef", nil
+		},
+		UploadPartFunc: func(bucketName, objectName, uploadID string, partNumber int, data io.Reader, contentSha256 string) (string, error) {
+			called = append(called, fmt.Sprintf("part:%d", partNumber))
+			return `"part-etag"`, nil
+		},
+		CompleteMultipartUploadFunc: func(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (string, error) {
+			called = append(called, fmt.Sprintf("complete:%d", len(parts)))
+			return `"d41d8cd98f00b204e9800998ecf8427e-2"`, nil
+		},
+		AbortMultipartUploadFunc: func(bucketName, objectName, uploadID string) error {
+			called = append(called, "abort")
+			return storage.ErrNoSuchUpload
+		},
+		ListPartsFunc: func(bucketName, objectName, uploadID string) ([]dto.Part, error) {
+			called = append(called, "list-parts")
+			return []dto.Part{{PartNumber: 1}, {PartNumber: 2}, {PartNumber: 3}}, nil
+		},
+		ListMultipartUploadsFunc: func(bucketName string) ([]dto.MultipartUpload, error) {
+			called = append(called, "list-uploads")
+			return []dto.MultipartUpload{{Key: "dir/big.bin", UploadId: "0123456789abcdef0123456789abcdef"}}, nil
+		},
+	}
+
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	completeBody := `<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>"a"</ETag></Part><Part><PartNumber>2</PartNumber><ETag>"b"</ETag></Part></CompleteMultipartUpload>`
+	uploadID := "0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		method       string
+		url          string
+		body         string
+		expectedCode int
+		expectedCall string
+	}{
+		{"POST", "/test-bucket/dir/big.bin?uploads", "", http.StatusOK, "create:dir/big.bin"},
+		{"PUT", "/test-bucket/dir/big.bin?partNumber=2&uploadId=" + uploadID, "data", http.StatusOK, "part:2"},
+		{"PUT", "/test-bucket/dir/big.bin?partNumber=10001&uploadId=" + uploadID, "data", http.StatusBadRequest, ""},
+		{"POST", "/test-bucket/dir/big.bin?uploadId=" + uploadID, completeBody, http.StatusOK, "complete:2"},
+		{"POST", "/test-bucket/dir/big.bin?uploadId=" + uploadID, "<CompleteMultipartUpload/>", http.StatusBadRequest, ""},
+		{"DELETE", "/test-bucket/dir/big.bin?uploadId=" + uploadID, "", http.StatusNotFound, "abort"},
+		{"GET", "/test-bucket/dir/big.bin?uploadId=" + uploadID + "&max-parts=2", "", http.StatusOK, "list-parts"},
+		{"GET", "/test-bucket/?uploads", "", http.StatusOK, "list-uploads"},
+	}
+
+	for _, tt := range tests {
+		called = nil
+		req, err := http.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s %s: expected status %d but got %d", tt.method, tt.url, tt.expectedCode, rr.Code)
+		}
+		if tt.expectedCall != "" && (len(called) != 1 || called[0] != tt.expectedCall) {
+			t.Errorf("%s %s: expected storage call %q but got %v", tt.method, tt.url, tt.expectedCall, called)
+		}
+		if tt.expectedCall == "" && len(called) != 0 {
+			t.Errorf("%s %s: expected no storage call but got %v", tt.method, tt.url, called)
+		}
+
+		if tt.expectedCall == "list-parts" {
+			var result dto.ListPartsResult
+			if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Error unmarshaling response body: %v", err)
+			}
+			if len(result.Parts) != 2 || !result.IsTruncated || result.NextPartNumberMarker != 2 {
+				t.Errorf("expected a truncated page of 2 parts, got %+v", result)
+			}
+		}
+	}
+}
+
+// Every failure is reported as an S3 XML error with a code, the resource and request identifiers
+func TestErrorResponses(t *testing.T) {
+	fs := storage.NewFileStorage(t.TempDir())
+	if err := fs.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	r := router.SetupRouterWithStorage(fs)
+
+	tests := []struct {
+		method       string
+		url          string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"GET", "/test-bucket/missing.txt", http.StatusNotFound, "NoSuchKey"},
+		{"GET", "/missing-bucket/", http.StatusNotFound, "NoSuchBucket"},
+		{"PUT", "/test-bucket/", http.StatusConflict, "BucketAlreadyExists"},
+		{"DELETE", "/missing-bucket/", http.StatusNotFound, "NoSuchBucket"},
+		{"GET", "/test-bucket/?max-keys=abc", http.StatusBadRequest, "InvalidArgument"},
+		{"GET", "/test-bucket/key?uploadId=0123456789abcdef0123456789abcdef", http.StatusNotFound, "NoSuchUpload"},
+		{"PATCH", "/test-bucket/key", http.StatusMethodNotAllowed, "MethodNotAllowed"},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, tt.url, nil)
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+		signRequest(req)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s %s: expected status %d but got %d", tt.method, tt.url, tt.expectedCode, rr.Code)
+		}
+		if contentType := rr.Header().Get("Content-Type"); contentType != "application/xml" {
+			t.Errorf("%s %s: expected an XML content type but got %q", tt.method, tt.url, contentType)
+		}
+
+		var errorResponse dto.ErrorResponse
+		if err := xml.Unmarshal(rr.Body.Bytes(), &errorResponse); err != nil {
+			t.Fatalf("%s %s: could not decode error response %q: %v", tt.method, tt.url, rr.Body.String(), err)
+		}
+		if errorResponse.Code != tt.expectedErr {
+			t.Errorf("%s %s: expected error code %s but got %s", tt.method, tt.url, tt.expectedErr, errorResponse.Code)
+		}
+		if errorResponse.Message == "" || errorResponse.HostId == "" {
+			t.Errorf("%s %s: expected a message and a host id in %+v", tt.method, tt.url, errorResponse)
+		}
+		if errorResponse.Resource != req.URL.Path {
+			t.Errorf("%s %s: expected resource %q but got %q", tt.method, tt.url, req.URL.Path, errorResponse.Resource)
+		}
+		if errorResponse.RequestId == "" || rr.Header().Get("x-amz-request-id") != errorResponse.RequestId {
+			t.Errorf("%s %s: expected the request id %q in the x-amz-request-id header", tt.method, tt.url, errorResponse.RequestId)
+		}
+	}
+}
+
+// Each request gets its own ID, returned in x-amz-request-id, error bodies and log lines
+func TestRequestIDs(t *testing.T) {
+	mockStorage := &MockStorage{
+		ListBucketsFunc: func() []string {
+			return []string{"bucket1"}
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage)
+
+	logs := captureLogs(t, "info", "text")
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatalf("could not create request: %v", err)
+		}
+		signRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := serve("/")
+	second := serve("/test-bucket/missing")
+
+	firstID := first.Header().Get("x-amz-request-id")
+	secondID := second.Header().Get("x-amz-request-id")
+	if len(firstID) != 16 || len(secondID) != 16 || firstID == secondID {
+		t.Fatalf("expected two distinct 16-character request IDs, got %q and %q", firstID, secondID)
+	}
+	if first.Header().Get("x-amz-id-2") == "" {
+		t.Errorf("expected an x-amz-id-2 header")
+	}
+
+	var errorResponse dto.ErrorResponse
+	if err := xml.Unmarshal(second.Body.Bytes(), &errorResponse); err != nil {
+		t.Fatalf("could not decode error response: %v", err)
+	}
+	if errorResponse.RequestId != secondID {
+		t.Errorf("expected the error body to carry request ID %q but got %q", secondID, errorResponse.RequestId)
+	}
+
+	for _, id := range []string{firstID, secondID} {
+		if !strings.Contains(logs.String(), "request_id="+id) {
+			t.Errorf("expected the log lines of request %s to carry its ID", id)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// Backends that must honour the storage.Storage contract, each one built empty for a test
+var storageBackends = map[string]func(t *testing.T) storage.Storage{
+	"FileStorage": func(t *testing.T) storage.Storage {
+		return storage.NewFileStorage(t.TempDir())
+	},
+}
+
+// Contract shared by every backend: handlers only rely on these errors to build S3 responses
+func TestStorageContract(t *testing.T) {
+	for name, newStorage := range storageBackends {
+		t.Run(name, func(t *testing.T) {
+			t.Run("buckets", func(t *testing.T) { testBucketContract(t, newStorage(t)) })
+			t.Run("non-empty bucket", func(t *testing.T) { testNonEmptyBucketContract(t, newStorage(t)) })
+			t.Run("bucket metadata", func(t *testing.T) { testBucketMetadataContract(t, newStorage(t)) })
+			t.Run("missing bucket", func(t *testing.T) { testMissingBucketContract(t, newStorage(t)) })
+			t.Run("objects", func(t *testing.T) { testObjectContract(t, newStorage(t)) })
+			t.Run("folder objects", func(t *testing.T) { testFolderObjectContract(t, newStorage(t)) })
+			t.Run("object metadata", func(t *testing.T) { testObjectMetadataContract(t, newStorage(t)) })
+			t.Run("copy", func(t *testing.T) { testCopyObjectContract(t, newStorage(t)) })
+		})
+	}
+}
+
+func expectError(t *testing.T, operation string, err, expected error) {
+	t.Helper()
+	if !errors.Is(err, expected) {
+		t.Errorf("%s: expected error %v but got %v", operation, expected, err)
+	}
+}
+
+func testBucketContract(t *testing.T, s storage.Storage) {
+	if err := s.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	expectError(t, "CreateBucket twice", s.CreateBucket("test-bucket", dto.BucketMetadata{}), storage.ErrBucketExists)
+
+	exists, err := s.CheckBucketExists("test-bucket")
+	if err != nil || !exists {
+		t.Errorf("expected the created bucket to exist, got %v, %v", exists, err)
+	}
+
+	for _, name := range []string{"", ".", "..", ".s3clone", ".minio.sys", "a/b", "ab", strings.Repeat("a", 64), "Bucket", "my_bucket", "my..bucket", "-bucket", "bucket-", "my.-bucket", "192.168.5.4", "xn--bucket", "bucket-s3alias"} {
+		expectError(t, "CreateBucket "+name, s.CreateBucket(name, dto.BucketMetadata{}), storage.ErrInvalidName)
+		if exists, err := s.CheckBucketExists(name); exists || err != nil {
+			t.Errorf("CheckBucketExists %q: expected false without error, got %v, %v", name, exists, err)
+		}
+	}
+
+	for _, name := range []string{"abc", strings.Repeat("a", 63), "my.bucket-01", "192.168.5.bucket"} {
+		if err := s.CreateBucket(name, dto.BucketMetadata{}); err != nil {
+			t.Errorf("CreateBucket %q: expected a valid bucket name, got %v", name, err)
+		}
+	}
+
+	if err := s.DeleteBucket("test-bucket"); err != nil {
+		t.Fatalf("could not delete bucket: %v", err)
+	}
+	expectError(t, "DeleteBucket twice", s.DeleteBucket("test-bucket"), storage.ErrBucketNotFound)
+}
+
+func testNonEmptyBucketContract(t *testing.T, s storage.Storage) {
+	if err := s.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	if _, err := s.AddObject("test-bucket", "dir/key.txt", bytes.NewReader([]byte("data")), "", dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not add object: %v", err)
+	}
+
+	expectError(t, "DeleteBucket with an object", s.DeleteBucket("test-bucket"), storage.ErrBucketNotEmpty)
+	if exists, _, err := s.CheckObjectExist("test-bucket", "dir/key.txt"); !exists || err != nil {
+		t.Fatalf("expected the object to be kept, got %v, %v", exists, err)
+	}
+
+	// Pending multipart uploads do not keep a bucket from being deleted, they are removed with it
+	if _, err := s.CreateMultipartUpload("test-bucket", "pending.txt", dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not create multipart upload: %v", err)
+	}
+	if err := s.DeleteObject("test-bucket", "dir/key.txt"); err != nil {
+		t.Fatalf("could not delete object: %v", err)
+	}
+	if err := s.DeleteBucket("test-bucket"); err != nil {
+		t.Fatalf("could not delete emptied bucket: %v", err)
+	}
+
+	if err := s.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket again: %v", err)
+	}
+	if uploads, err := s.ListMultipartUploads("test-bucket"); err != nil || len(uploads) != 0 {
+		t.Errorf("expected the uploads to be deleted with their bucket, got %v (%v)", uploads, err)
+	}
+}
+
+func testBucketMetadataContract(t *testing.T, s storage.Storage) {
+	before := time.Now()
+	meta := dto.BucketMetadata{Region: "eu-west-3", Owner: "alice"}
+	if err := s.CreateBucket("eu-bucket", meta); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	if err := s.CreateBucket("plain-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	after := time.Now()
+
+	stored, err := s.GetBucketMetadata("eu-bucket")
+	if err != nil {
+		t.Fatalf("could not get bucket metadata: %v", err)
+	}
+	if stored.CreationDate.Before(before.Add(-time.Second)) || stored.CreationDate.After(after.Add(time.Second)) {
+		t.Errorf("expected a creation date between %v and %v, got %v", before, after, stored.CreationDate)
+	}
+	meta.CreationDate = stored.CreationDate
+	if !reflect.DeepEqual(stored, meta) {
+		t.Errorf("expected bucket metadata %+v but got %+v", meta, stored)
+	}
+
+	// The creation date does not change as objects are written
+	if _, err := s.AddObject("eu-bucket", "key.txt", bytes.NewReader([]byte("data")), "", dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not add object: %v", err)
+	}
+	if again, err := s.GetBucketMetadata("eu-bucket"); err != nil || !again.CreationDate.Equal(stored.CreationDate) {
+		t.Errorf("expected the creation date to be kept, got %v (%v)", again.CreationDate, err)
+	}
+
+	// Buckets created without a location are in the default region
+	if plain, err := s.GetBucketMetadata("plain-bucket"); err != nil || plain.Region != storage.DefaultRegion || plain.CreationDate.IsZero() || plain.Owner != "" {
+		t.Errorf("expected default bucket metadata, got %+v (%v)", plain, err)
+	}
+
+	// A deleted bucket leaves no metadata behind for a new bucket with the same name
+	if err := s.DeleteBucket("plain-bucket"); err != nil {
+		t.Fatalf("could not delete bucket: %v", err)
+	}
+	_, err = s.GetBucketMetadata("plain-bucket")
+	expectError(t, "GetBucketMetadata after delete", err, storage.ErrBucketNotFound)
+	if err := s.CreateBucket("plain-bucket", dto.BucketMetadata{Owner: "bob"}); err != nil {
+		t.Fatalf("could not create bucket again: %v", err)
+	}
+	if plain, err := s.GetBucketMetadata("plain-bucket"); err != nil || plain.Owner != "bob" {
+		t.Errorf("expected the metadata of the new bucket, got %+v (%v)", plain, err)
+	}
+}
+
+func testMissingBucketContract(t *testing.T, s storage.Storage) {
+	if exists, err := s.CheckBucketExists("missing"); exists || err != nil {
+		t.Errorf("CheckBucketExists: expected false without error, got %v, %v", exists, err)
+	}
+
+	_, err := s.AddObject("missing", "key", bytes.NewReader([]byte("data")), "", dto.ObjectMetadata{})
+	expectError(t, "AddObject", err, storage.ErrBucketNotFound)
+
+	_, _, err = s.GetObject("missing", "key")
+	expectError(t, "GetObject", err, storage.ErrBucketNotFound)
+
+	_, _, err = s.CheckObjectExist("missing", "key")
+	expectError(t, "CheckObjectExist", err, storage.ErrBucketNotFound)
+
+	expectError(t, "DeleteObject", s.DeleteObject("missing", "key"), storage.ErrBucketNotFound)
+	expectError(t, "DeleteBucket", s.DeleteBucket("missing"), storage.ErrBucketNotFound)
+
+	_, err = s.ListObjects("missing", "", "", "", 1000)
+	expectError(t, "ListObjects", err, storage.ErrBucketNotFound)
+
+	_, err = s.CreateMultipartUpload("missing", "key", dto.ObjectMetadata{})
+	expectError(t, "CreateMultipartUpload", err, storage.ErrBucketNotFound)
+
+	_, err = s.ListMultipartUploads("missing")
+	expectError(t, "ListMultipartUploads", err, storage.ErrBucketNotFound)
+
+	_, err = s.GetBucketMetadata("missing")
+	expectError(t, "GetBucketMetadata", err, storage.ErrBucketNotFound)
+}
+
+func testObjectContract(t *testing.T, s storage.Storage) {
+	if err := s.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+
+	content := []byte("object content")
+	if _, err := s.AddObject("test-bucket", "dir/key.txt", bytes.NewReader(content), "", dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not add object: %v", err)
+	}
+
+	reader, info, err := s.GetObject("test-bucket", "dir/key.txt")
+	if err != nil {
+		t.Fatalf("could not get object: %v", err)
+	}
+	stored, _ := io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(stored, content) || info.Size() != int64(len(content)) || info.ETag() == "" {
+		t.Errorf("unexpected object %q (size %d, ETag %q)", stored, info.Size(), info.ETag())
+	}
+
+	// "dir" only exists as a prefix of "dir/key.txt", it is not an object
+	for _, key := range []string{"missing.txt", "dir", "dir/"} {
+		_, _, err := s.GetObject("test-bucket", key)
+		expectError(t, "GetObject "+key, err, storage.ErrObjectNotFound)
+		expectError(t, "DeleteObject "+key, s.DeleteObject("test-bucket", key), storage.ErrObjectNotFound)
+
+		exists, _, err := s.CheckObjectExist("test-bucket", key)
+		if exists || err != nil {
+			t.Errorf("CheckObjectExist %q: expected false without error, got %v, %v", key, exists, err)
+		}
+	}
+
+	_, err = s.AddObject("test-bucket", "", bytes.NewReader(content), "", dto.ObjectMetadata{})
+	expectError(t, "AddObject with an empty key", err, storage.ErrInvalidName)
+
+	_, err = s.UploadPart("test-bucket", "key", "0123456789abcdef0123456789abcdef", 1, bytes.NewReader(content), "")
+	expectError(t, "UploadPart", err, storage.ErrNoSuchUpload)
+
+	if err := s.DeleteObject("test-bucket", "dir/key.txt"); err != nil {
+		t.Fatalf("could not delete object: %v", err)
+	}
+	_, _, err = s.GetObject("test-bucket", "dir/key.txt")
+	expectError(t, "GetObject after delete", err, storage.ErrObjectNotFound)
+	expectError(t, "DeleteObject twice", s.DeleteObject("test-bucket", "dir/key.txt"), storage.ErrObjectNotFound)
+}
+
+// Keys ending with "/" are the folders created by S3 clients: they coexist with the keys below them,
+// while a key cannot be both an object and the prefix of other objects
+func testFolderObjectContract(t *testing.T, s storage.Storage) {
+	if err := s.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+
+	for _, key := range []string{"folder/", "folder/x"} {
+		if _, err := s.AddObject("test-bucket", key, bytes.NewReader([]byte(key)), "", dto.ObjectMetadata{}); err != nil {
+			t.Fatalf("AddObject %q: %v", key, err)
+		}
+	}
+	for _, key := range []string{"folder/", "folder/x"} {
+		reader, _, err := s.GetObject("test-bucket", key)
+		if err != nil {
+			t.Fatalf("GetObject %q: %v", key, err)
+		}
+		stored, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(stored) != key {
+			t.Errorf("GetObject %q: expected %q but got %q", key, key, stored)
+		}
+	}
+	_, _, err := s.GetObject("test-bucket", "folder")
+	expectError(t, "GetObject folder", err, storage.ErrObjectNotFound)
+
+	list, err := s.ListObjects("test-bucket", "", "", "", 1000)
+	if err != nil {
+		t.Fatalf("ListObjects: %v", err)
+	}
+	var keys []string
+	for _, object := range list.Contents {
+		keys = append(keys, object.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"folder/", "folder/x"}) {
+		t.Errorf("ListObjects: expected [folder/ folder/x] but got %v", keys)
+	}
+
+	_, err = s.AddObject("test-bucket", "folder", bytes.NewReader([]byte("data")), "", dto.ObjectMetadata{})
+	expectError(t, "AddObject over a folder", err, storage.ErrObjectExistsAsDirectory)
+
+	if _, err := s.AddObject("test-bucket", "a", bytes.NewReader([]byte("data")), "", dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not add object: %v", err)
+	}
+	for _, key := range []string{"a/b", "a/"} {
+		_, err = s.AddObject("test-bucket", key, bytes.NewReader([]byte("data")), "", dto.ObjectMetadata{})
+		expectError(t, "AddObject "+key+" below an object", err, storage.ErrParentIsObject)
+		_, err = s.CopyObject("test-bucket", "folder/x", "test-bucket", key, nil)
+		expectError(t, "CopyObject "+key+" below an object", err, storage.ErrParentIsObject)
+		_, _, err = s.GetObject("test-bucket", key)
+		expectError(t, "GetObject "+key+" below an object", err, storage.ErrObjectNotFound)
+		expectError(t, "DeleteObject "+key+" below an object", s.DeleteObject("test-bucket", key), storage.ErrObjectNotFound)
+	}
+
+	for _, key := range []string{"folder/", "folder/x", "a"} {
+		if err := s.DeleteObject("test-bucket", key); err != nil {
+			t.Fatalf("DeleteObject %q: %v", key, err)
+		}
+	}
+	if err := s.DeleteBucket("test-bucket"); err != nil {
+		t.Errorf("expected an empty bucket after deleting every object, got %v", err)
+	}
+}
+
+func testObjectMetadataContract(t *testing.T, s storage.Storage) {
+	if err := s.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+
+	meta := dto.ObjectMetadata{
+		ContentType:        "text/plain",
+		ContentEncoding:    "gzip",
+		CacheControl:       "max-age=60",
+		ContentDisposition: `inline; filename="key.txt"`,
+		UserMetadata:       map[string]string{"author": "alice"},
+	}
+	if _, err := s.AddObject("test-bucket", "key.txt", bytes.NewReader([]byte("content")), "", meta); err != nil {
+		t.Fatalf("could not add object: %v", err)
+	}
+
+	reader, info, err := s.GetObject("test-bucket", "key.txt")
+	if err != nil {
+		t.Fatalf("could not get object: %v", err)
+	}
+	reader.Close()
+	if !reflect.DeepEqual(info.Metadata(), meta) {
+		t.Errorf("GetObject: expected metadata %+v but got %+v", meta, info.Metadata())
+	}
+	if _, info, err := s.CheckObjectExist("test-bucket", "key.txt"); err != nil || !reflect.DeepEqual(info.Metadata(), meta) {
+		t.Errorf("CheckObjectExist: expected metadata %+v but got %+v (%v)", meta, info.Metadata(), err)
+	}
+
+	// Overwriting an object replaces its metadata
+	if _, err := s.AddObject("test-bucket", "key.txt", bytes.NewReader([]byte("content")), "", dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not overwrite object: %v", err)
+	}
+	if _, info, err := s.CheckObjectExist("test-bucket", "key.txt"); err != nil || !reflect.DeepEqual(info.Metadata(), dto.ObjectMetadata{}) {
+		t.Errorf("expected the overwritten object to have no metadata, got %+v (%v)", info.Metadata(), err)
+	}
+
+	// Multipart uploads apply the metadata given at initiation
+	uploadID, err := s.CreateMultipartUpload("test-bucket", "multipart.txt", meta)
+	if err != nil {
+		t.Fatalf("could not create multipart upload: %v", err)
+	}
+	etag, err := s.UploadPart("test-bucket", "multipart.txt", uploadID, 1, bytes.NewReader([]byte("part")), "")
+	if err != nil {
+		t.Fatalf("could not upload part: %v", err)
+	}
+	if _, err := s.CompleteMultipartUpload("test-bucket", "multipart.txt", uploadID, []dto.CompletedPart{{PartNumber: 1, ETag: etag}}); err != nil {
+		t.Fatalf("could not complete multipart upload: %v", err)
+	}
+	if _, info, err := s.CheckObjectExist("test-bucket", "multipart.txt"); err != nil || !reflect.DeepEqual(info.Metadata(), meta) {
+		t.Errorf("expected the multipart object to carry its metadata, got %+v (%v)", info.Metadata(), err)
+	}
+}
+
+func testCopyObjectContract(t *testing.T, s storage.Storage) {
+	for _, bucket := range []string{"src-bucket", "dst-bucket"} {
+		if err := s.CreateBucket(bucket, dto.BucketMetadata{}); err != nil {
+			t.Fatalf("could not create bucket: %v", err)
+		}
+	}
+
+	content := []byte("copied content")
+	meta := dto.ObjectMetadata{ContentType: "text/plain", UserMetadata: map[string]string{"author": "alice"}}
+	etag, err := s.AddObject("src-bucket", "dir/source.txt", bytes.NewReader(content), "", meta)
+	if err != nil {
+		t.Fatalf("could not add object: %v", err)
+	}
+
+	// Without replacement metadata the source metadata is kept
+	info, err := s.CopyObject("src-bucket", "dir/source.txt", "dst-bucket", "nested/copy.txt", nil)
+	if err != nil {
+		t.Fatalf("could not copy object: %v", err)
+	}
+	if info.ETag() != etag || !reflect.DeepEqual(info.Metadata(), meta) {
+		t.Errorf("expected the copy to have ETag %s and metadata %+v, got %s and %+v", etag, meta, info.ETag(), info.Metadata())
+	}
+	reader, _, err := s.GetObject("dst-bucket", "nested/copy.txt")
+	if err != nil {
+		t.Fatalf("could not get copy: %v", err)
+	}
+	copied, _ := io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(copied, content) {
+		t.Errorf("expected copied content %q but got %q", content, copied)
+	}
+
+	// Copying an object onto itself replaces its metadata
+	replaced := dto.ObjectMetadata{ContentType: "application/json"}
+	if _, err := s.CopyObject("src-bucket", "dir/source.txt", "src-bucket", "dir/source.txt", &replaced); err != nil {
+		t.Fatalf("could not copy object onto itself: %v", err)
+	}
+	if _, info, err := s.CheckObjectExist("src-bucket", "dir/source.txt"); err != nil || !reflect.DeepEqual(info.Metadata(), replaced) {
+		t.Errorf("expected replaced metadata %+v, got %+v (%v)", replaced, info.Metadata(), err)
+	}
+
+	_, err = s.CopyObject("src-bucket", "missing.txt", "dst-bucket", "copy.txt", nil)
+	expectError(t, "CopyObject from a missing key", err, storage.ErrObjectNotFound)
+	_, err = s.CopyObject("src-bucket", "dir", "dst-bucket", "copy.txt", nil)
+	expectError(t, "CopyObject from a prefix", err, storage.ErrObjectNotFound)
+	_, err = s.CopyObject("missing", "dir/source.txt", "dst-bucket", "copy.txt", nil)
+	expectError(t, "CopyObject from a missing bucket", err, storage.ErrBucketNotFound)
+	_, err = s.CopyObject("src-bucket", "dir/source.txt", "missing", "copy.txt", nil)
+	expectError(t, "CopyObject to a missing bucket", err, storage.ErrBucketNotFound)
+}
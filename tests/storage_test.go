@@ -0,0 +1,590 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// newTestFileStorage returns a FileStorage rooted in a temporary directory with one bucket
+func newTestFileStorage(t *testing.T, bucketName string) *storage.FileStorage {
+	t.Helper()
+
+	fs := storage.NewFileStorage(t.TempDir())
+	if err := fs.CreateBucket(bucketName, dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	return fs
+}
+
+// Pages through a bucket holding several thousand objects using NextMarker
+func TestFileStorageListObjectsPagination(t *testing.T) {
+	fs := newTestFileStorage(t, "big-bucket")
+
+	// Mix flat and nested keys so that directory order differs from key order ("a-b" < "a/b")
+	var expected []string
+	for i := 0; i < 3500; i++ {
+		var key string
+		switch i % 3 {
+		case 0:
+			key = fmt.Sprintf("obj-%05d", i)
+		case 1:
+			key = fmt.Sprintf("obj/%02d/%05d.log", i%50, i)
+		default:
+			key = fmt.Sprintf("obj-%05d.txt", i)
+		}
+		if _, err := fs.AddObject("big-bucket", key, strings.NewReader(key), "", dto.ObjectMetadata{}); err != nil {
+			t.Fatalf("could not add object %s: %v", key, err)
+		}
+		expected = append(expected, key)
+	}
+	sort.Strings(expected)
+
+	for _, pageSize := range []int{1000, 333} {
+		var listed []string
+		marker := ""
+		pages := 0
+
+		for {
+			response, err := fs.ListObjects("big-bucket", "", marker, "", pageSize)
+			if err != nil {
+				t.Fatalf("ListObjects failed: %v", err)
+			}
+			pages++
+			if pages > len(expected) {
+				t.Fatalf("pagination does not terminate with page size %d", pageSize)
+			}
+
+			if len(response.Contents) > pageSize {
+				t.Fatalf("expected at most %d keys per page but got %d", pageSize, len(response.Contents))
+			}
+			for _, obj := range response.Contents {
+				listed = append(listed, obj.Key)
+			}
+
+			if !response.IsTruncated {
+				if response.NextMarker != "" {
+					t.Errorf("expected no NextMarker on the last page, got %q", response.NextMarker)
+				}
+				break
+			}
+			if response.NextMarker != response.Contents[len(response.Contents)-1].Key {
+				t.Fatalf("expected NextMarker to be the last returned key, got %q", response.NextMarker)
+			}
+			marker = response.NextMarker
+		}
+
+		if len(listed) != len(expected) {
+			t.Fatalf("page size %d: expected %d keys but got %d", pageSize, len(expected), len(listed))
+		}
+		for i := range expected {
+			if listed[i] != expected[i] {
+				t.Fatalf("page size %d: expected key %q at position %d but got %q", pageSize, expected[i], i, listed[i])
+			}
+		}
+	}
+}
+
+func TestFileStorageListObjectsDelimiter(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+
+	keys := []string{"logs/2023/a.log", "logs/2024/b.log", "logs/2024/c.log", "logs/readme", "logs-old", "top.txt"}
+	for _, key := range keys {
+		if _, err := fs.AddObject("test-bucket", key, strings.NewReader(key), "", dto.ObjectMetadata{}); err != nil {
+			t.Fatalf("could not add object %s: %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		prefix           string
+		maxKeys          int
+		expectedKeys     []string
+		expectedPrefixes []string
+	}{
+		{"", 1000, []string{"logs-old", "top.txt"}, []string{"logs/"}},
+		{"logs/", 1000, []string{"logs/readme"}, []string{"logs/2023/", "logs/2024/"}},
+		{"logs/2024/", 1000, []string{"logs/2024/b.log", "logs/2024/c.log"}, nil},
+		// Paging one entry at a time must yield every key and prefix exactly once
+		{"logs/", 1, []string{"logs/readme"}, []string{"logs/2023/", "logs/2024/"}},
+	}
+
+	for _, tt := range tests {
+		var listedKeys, listedPrefixes []string
+		marker := ""
+		for {
+			response, err := fs.ListObjects("test-bucket", tt.prefix, marker, "/", tt.maxKeys)
+			if err != nil {
+				t.Fatalf("ListObjects failed: %v", err)
+			}
+			for _, obj := range response.Contents {
+				listedKeys = append(listedKeys, obj.Key)
+			}
+			for _, commonPrefix := range response.CommonPrefixes {
+				listedPrefixes = append(listedPrefixes, commonPrefix.Prefix)
+			}
+			if !response.IsTruncated {
+				break
+			}
+			marker = response.NextMarker
+		}
+
+		if fmt.Sprint(listedKeys) != fmt.Sprint(tt.expectedKeys) {
+			t.Errorf("prefix %q: expected keys %v but got %v", tt.prefix, tt.expectedKeys, listedKeys)
+		}
+		if fmt.Sprint(listedPrefixes) != fmt.Sprint(tt.expectedPrefixes) {
+			t.Errorf("prefix %q: expected common prefixes %v but got %v", tt.prefix, tt.expectedPrefixes, listedPrefixes)
+		}
+	}
+}
+
+func TestFileStorageMultipartUpload(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+	const key = "videos/big.bin"
+
+	uploadID, err := fs.CreateMultipartUpload("test-bucket", key, dto.ObjectMetadata{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload failed: %v", err)
+	}
+
+	uploads, err := fs.ListMultipartUploads("test-bucket")
+	if err != nil || len(uploads) != 1 || uploads[0].UploadId != uploadID || uploads[0].Key != key {
+		t.Fatalf("expected the upload to be listed, got %v (err %v)", uploads, err)
+	}
+
+	// Parts are uploaded out of order, all but the last one must be at least 5 MiB
+	partData := [][]byte{
+		bytes.Repeat([]byte("a"), 5<<20),
+		bytes.Repeat([]byte("b"), 5<<20),
+		[]byte("tail"),
+	}
+	etags := make([]string, len(partData))
+	for _, i := range []int{2, 0, 1} {
+		etags[i], err = fs.UploadPart("test-bucket", key, uploadID, i+1, bytes.NewReader(partData[i]), "")
+		if err != nil {
+			t.Fatalf("UploadPart %d failed: %v", i+1, err)
+		}
+	}
+
+	parts, err := fs.ListParts("test-bucket", key, uploadID)
+	if err != nil || len(parts) != 3 || parts[0].PartNumber != 1 || parts[2].Size != 4 {
+		t.Fatalf("unexpected parts listing %v (err %v)", parts, err)
+	}
+
+	// Invalid completions leave the upload untouched
+	invalid := []struct {
+		parts []dto.CompletedPart
+		err   error
+	}{
+		{[]dto.CompletedPart{{PartNumber: 2, ETag: etags[1]}, {PartNumber: 1, ETag: etags[0]}}, storage.ErrInvalidPartOrder},
+		{[]dto.CompletedPart{{PartNumber: 1, ETag: `"bad"`}}, storage.ErrInvalidPart},
+		{[]dto.CompletedPart{{PartNumber: 1, ETag: etags[0]}, {PartNumber: 5, ETag: etags[2]}}, storage.ErrInvalidPart},
+		{[]dto.CompletedPart{{PartNumber: 3, ETag: etags[2]}, {PartNumber: 4, ETag: etags[2]}}, storage.ErrEntityTooSmall},
+	}
+	if _, err := fs.UploadPart("test-bucket", key, uploadID, 4, bytes.NewReader(partData[2]), ""); err != nil {
+		t.Fatalf("UploadPart 4 failed: %v", err)
+	}
+	for _, tt := range invalid {
+		if _, err := fs.CompleteMultipartUpload("test-bucket", key, uploadID, tt.parts); !errors.Is(err, tt.err) {
+			t.Errorf("expected error %v but got %v", tt.err, err)
+		}
+	}
+
+	completed := []dto.CompletedPart{
+		{PartNumber: 1, ETag: etags[0]},
+		{PartNumber: 2, ETag: etags[1]},
+		{PartNumber: 3, ETag: etags[2]},
+	}
+	etag, err := fs.CompleteMultipartUpload("test-bucket", key, uploadID, completed)
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload failed: %v", err)
+	}
+
+	// S3-style multipart ETag: MD5 of the concatenated binary part MD5s, then the part count
+	var md5s []byte
+	for _, data := range partData {
+		sum := md5.Sum(data)
+		md5s = append(md5s, sum[:]...)
+	}
+	sum := md5.Sum(md5s)
+	expectedETag := fmt.Sprintf(`"%s-3"`, hex.EncodeToString(sum[:]))
+	if etag != expectedETag {
+		t.Errorf("expected ETag %s but got %s", expectedETag, etag)
+	}
+
+	reader, info, err := fs.GetObject("test-bucket", key)
+	if err != nil {
+		t.Fatalf("GetObject failed: %v", err)
+	}
+	content, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("could not read object: %v", err)
+	}
+	if !bytes.Equal(content, bytes.Join(partData, nil)) {
+		t.Errorf("assembled object does not match the uploaded parts")
+	}
+	if info.ETag() != expectedETag {
+		t.Errorf("expected stored ETag %s but got %s", expectedETag, info.ETag())
+	}
+
+	// The upload is gone once completed
+	if _, err := fs.ListParts("test-bucket", key, uploadID); !errors.Is(err, storage.ErrNoSuchUpload) {
+		t.Errorf("expected ErrNoSuchUpload after completion but got %v", err)
+	}
+	if err := fs.AbortMultipartUpload("test-bucket", key, "../../../etc"); !errors.Is(err, storage.ErrNoSuchUpload) {
+		t.Errorf("expected ErrNoSuchUpload for a malformed upload id but got %v", err)
+	}
+}
+
+// Keys that the filesystem would interpret instead of storing are rejected
+func TestFileStoragePathTraversal(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+
+	for _, key := range []string{"../escape", "../../etc/passwd", "a/../../b", "/absolute", "a//b", "./a", "a/.", "a/..", "a\x00b", "/"} {
+		_, err := fs.AddObject("test-bucket", key, strings.NewReader("data"), "", dto.ObjectMetadata{})
+		expectError(t, "AddObject "+key, err, storage.ErrInvalidName)
+		_, _, err = fs.GetObject("test-bucket", key)
+		expectError(t, "GetObject "+key, err, storage.ErrInvalidName)
+		expectError(t, "DeleteObject "+key, fs.DeleteObject("test-bucket", key), storage.ErrInvalidName)
+	}
+
+	// Dots are only special as whole segments
+	for _, key := range []string{"..a", "a..", "a/...", ".hidden"} {
+		if _, err := fs.AddObject("test-bucket", key, strings.NewReader("data"), "", dto.ObjectMetadata{}); err != nil {
+			t.Errorf("AddObject %q: expected a valid key, got %v", key, err)
+		}
+	}
+}
+
+// Keys longer than S3 allows, or with a segment longer than the filesystem allows, are client errors
+func TestFileStorageKeyTooLong(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+
+	for name, key := range map[string]string{
+		"key over 1024 bytes":    strings.Repeat("a/", 512) + "b",
+		"segment over 255 bytes": "dir/" + strings.Repeat("a", 256),
+		"folder over 255 bytes":  strings.Repeat("a", 256) + "/key.txt",
+	} {
+		_, err := fs.AddObject("test-bucket", key, strings.NewReader("data"), "", dto.ObjectMetadata{})
+		expectError(t, "AddObject with a "+name, err, storage.ErrKeyTooLong)
+		_, _, err = fs.GetObject("test-bucket", key)
+		if !errors.Is(err, storage.ErrKeyTooLong) && !errors.Is(err, storage.ErrObjectNotFound) {
+			t.Errorf("GetObject with a %s: expected a client error, got %v", name, err)
+		}
+	}
+
+	key := strings.Repeat("a/", 511) + "bc"
+	if _, err := fs.AddObject("test-bucket", key, strings.NewReader("data"), "", dto.ObjectMetadata{}); err != nil {
+		t.Errorf("AddObject with a 1024-byte key: expected success, got %v", err)
+	}
+}
+
+// Symlinks found in the storage cannot be used to read or write outside of it
+func TestFileStorageSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Join(dir, "root")
+	fs := storage.NewFileStorage(root)
+	if err := fs.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "test-bucket", "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "linked-bucket")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := fs.GetObject("test-bucket", "link/secret.txt")
+	expectError(t, "GetObject through a symlink", err, storage.ErrInvalidName)
+	_, err = fs.AddObject("test-bucket", "link/new.txt", strings.NewReader("data"), "", dto.ObjectMetadata{})
+	expectError(t, "AddObject through a symlink", err, storage.ErrInvalidName)
+	expectError(t, "DeleteObject through a symlink", fs.DeleteObject("test-bucket", "link/secret.txt"), storage.ErrInvalidName)
+	_, _, err = fs.GetObject("linked-bucket", "secret.txt")
+	expectError(t, "GetObject in a symlinked bucket", err, storage.ErrInvalidName)
+
+	if _, err := os.Stat(filepath.Join(outside, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written outside of the storage, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret.txt")); err != nil {
+		t.Errorf("expected the file outside of the storage to be kept, got %v", err)
+	}
+}
+
+// Whatever the key, objects stay inside their bucket and read back what was written
+func FuzzFileStorageObjectKeys(f *testing.F) {
+	for _, key := range []string{"key.txt", "dir/key.txt", "dir/", "../escape", "a/../../b", "/absolute", "a//b", ".", "..", "a\x00b", ".s3clone/meta/key", "a\\..\\b", "%2e%2e/escape"} {
+		f.Add(key)
+	}
+
+	f.Fuzz(func(t *testing.T, key string) {
+		dir := t.TempDir()
+		root := filepath.Join(dir, "root")
+		fs := storage.NewFileStorage(root)
+		if err := fs.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+			t.Fatalf("could not create bucket: %v", err)
+		}
+
+		content := []byte("fuzz content")
+		_, addErr := fs.AddObject("test-bucket", key, bytes.NewReader(content), "", dto.ObjectMetadata{})
+
+		// Only the bucket and the internal directory may be written to
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			if !strings.HasPrefix(rel, "test-bucket"+string(filepath.Separator)) && !strings.HasPrefix(rel, ".s3clone"+string(filepath.Separator)) {
+				t.Errorf("AddObject %q wrote %s outside of the bucket", key, path)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("could not walk the storage: %v", err)
+		}
+
+		reader, _, getErr := fs.GetObject("test-bucket", key)
+		if addErr != nil {
+			if getErr == nil {
+				reader.Close()
+				t.Errorf("GetObject %q succeeded although AddObject failed with %v", key, addErr)
+			}
+			return
+		}
+		if getErr != nil {
+			t.Fatalf("GetObject %q failed after a successful AddObject: %v", key, getErr)
+		}
+		stored, _ := io.ReadAll(reader)
+		reader.Close()
+		if !bytes.Equal(stored, content) {
+			t.Errorf("GetObject %q: expected %q but got %q", key, content, stored)
+		}
+
+		if err := fs.DeleteObject("test-bucket", key); err != nil {
+			t.Errorf("DeleteObject %q failed: %v", key, err)
+		}
+		if entries, err := os.ReadDir(filepath.Join(root, "test-bucket")); err != nil || len(entries) != 0 {
+			t.Errorf("DeleteObject %q: expected an empty bucket, got %d entries (%v)", key, len(entries), err)
+		}
+	})
+}
+
+// Buckets created before their metadata was recorded get the date of their directory, frozen on first read
+func TestFileStorageLegacyBucketMetadata(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "legacy-bucket"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(root, "legacy-bucket"), created, created); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := storage.NewFileStorage(root)
+	meta, err := fs.GetBucketMetadata("legacy-bucket")
+	if err != nil {
+		t.Fatalf("could not get bucket metadata: %v", err)
+	}
+	if !meta.CreationDate.Equal(created) || meta.Region != storage.DefaultRegion {
+		t.Errorf("expected the directory date %v in the default region, got %+v", created, meta)
+	}
+
+	// Writing an object changes the directory date, not the recorded creation date
+	if _, err := fs.AddObject("legacy-bucket", "key.txt", strings.NewReader("data"), "", dto.ObjectMetadata{}); err != nil {
+		t.Fatalf("could not add object: %v", err)
+	}
+	if meta, err := fs.GetBucketMetadata("legacy-bucket"); err != nil || !meta.CreationDate.Equal(created) {
+		t.Errorf("expected the creation date to stay %v, got %v (%v)", created, meta.CreationDate, err)
+	}
+}
+
+// Bucket usage is computed from the files alone: objects written without metadata are not hashed
+func TestFileStorageBucketUsage(t *testing.T) {
+	root := t.TempDir()
+	fs := storage.NewFileStorage(root)
+	if err := fs.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+	for _, key := range []string{"key.txt", "dir/", "dir/nested.txt"} {
+		if _, err := fs.AddObject("test-bucket", key, strings.NewReader("data"), "", dto.ObjectMetadata{}); err != nil {
+			t.Fatalf("could not add object %q: %v", key, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "test-bucket", "legacy.txt"), []byte("legacy data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := fs.BucketUsage("test-bucket")
+	if err != nil {
+		t.Fatalf("could not compute bucket usage: %v", err)
+	}
+	if usage.Objects != 4 || usage.Bytes != 3*4+11 {
+		t.Errorf("expected 4 objects and %d bytes, got %+v", 3*4+11, usage)
+	}
+
+	var sidecars int
+	filepath.Walk(filepath.Join(root, ".s3clone", "meta"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			sidecars++
+		}
+		return nil
+	})
+	if sidecars != 3 {
+		t.Errorf("expected BucketUsage not to write metadata for the legacy object, found %d metadata files", sidecars)
+	}
+
+	_, err = fs.BucketUsage("missing")
+	expectError(t, "BucketUsage", err, storage.ErrBucketNotFound)
+}
+
+// Overwriting a key replaces both the content and its ETag, without leaving any other file behind
+func TestFileStorageOverwriteObject(t *testing.T) {
+	root := t.TempDir()
+	fs := storage.NewFileStorage(root)
+	if err := fs.CreateBucket("test-bucket", dto.BucketMetadata{}); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+
+	for _, content := range []string{"first version", "second"} {
+		if _, err := fs.AddObject("test-bucket", "key.txt", strings.NewReader(content), "", dto.ObjectMetadata{ContentType: content}); err != nil {
+			t.Fatalf("could not add object: %v", err)
+		}
+	}
+
+	reader, info, err := fs.GetObject("test-bucket", "key.txt")
+	if err != nil {
+		t.Fatalf("could not get object: %v", err)
+	}
+	stored, _ := io.ReadAll(reader)
+	reader.Close()
+	sum := md5.Sum([]byte("second"))
+	if string(stored) != "second" || info.ETag() != `"`+hex.EncodeToString(sum[:])+`"` || info.Metadata().ContentType != "second" {
+		t.Errorf("expected the second version, got %q with ETag %s and metadata %+v", stored, info.ETag(), info.Metadata())
+	}
+
+	entries, err := os.ReadDir(filepath.Join(root, "test-bucket"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the object in the bucket, got %d entries (%v)", len(entries), err)
+	}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && (strings.HasSuffix(path, "-1") || strings.HasPrefix(info.Name(), ".tmp-")) {
+			t.Errorf("unexpected file %s left by the overwrite", path)
+		}
+		return nil
+	})
+}
+
+// Concurrent writers of a key never leave one writer's content with another writer's ETag or metadata
+func TestFileStorageConcurrentOverwrites(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+
+	const writers, rounds = 4, 20
+	done := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		go func(i int) {
+			content := strings.Repeat(fmt.Sprintf("writer %d ", i), 100*(i+1))
+			meta := dto.ObjectMetadata{ContentType: fmt.Sprintf("text/writer-%d", i)}
+			for round := 0; round < rounds; round++ {
+				if _, err := fs.AddObject("test-bucket", "key.txt", strings.NewReader(content), "", meta); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}(i)
+	}
+
+	for finished := 0; finished < writers; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("concurrent AddObject failed: %v", err)
+			}
+			finished++
+			continue
+		default:
+		}
+
+		reader, info, err := fs.GetObject("test-bucket", "key.txt")
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("could not get object: %v", err)
+		}
+		stored, _ := io.ReadAll(reader)
+		reader.Close()
+		sum := md5.Sum(stored)
+		if info.ETag() != `"`+hex.EncodeToString(sum[:])+`"` {
+			t.Fatalf("ETag %s does not match the content returned (%d bytes)", info.ETag(), len(stored))
+		}
+		if expected := "text/writer-" + strings.Fields(string(stored))[1]; info.Metadata().ContentType != expected {
+			t.Fatalf("expected the metadata of %s, got %q", expected, info.Metadata().ContentType)
+		}
+	}
+}
+
+// Concurrent uploads of the same part never leave one upload's content with another upload's ETag
+func TestFileStorageConcurrentPartUploads(t *testing.T) {
+	fs := newTestFileStorage(t, "test-bucket")
+	uploadID, err := fs.CreateMultipartUpload("test-bucket", "key.bin", dto.ObjectMetadata{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload failed: %v", err)
+	}
+
+	const writers, rounds = 4, 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			content := strings.Repeat(fmt.Sprintf("writer %d ", i), 100*(i+1))
+			for round := 0; round < rounds; round++ {
+				if _, err := fs.UploadPart("test-bucket", "key.bin", uploadID, 1, strings.NewReader(content), ""); err != nil {
+					t.Errorf("concurrent UploadPart failed: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	parts, err := fs.ListParts("test-bucket", "key.bin", uploadID)
+	if err != nil || len(parts) != 1 {
+		t.Fatalf("expected a single part, got %v (err %v)", parts, err)
+	}
+	if _, err := fs.CompleteMultipartUpload("test-bucket", "key.bin", uploadID, []dto.CompletedPart{{PartNumber: 1, ETag: parts[0].ETag}}); err != nil {
+		t.Fatalf("CompleteMultipartUpload with the listed ETag failed: %v", err)
+	}
+
+	reader, _, err := fs.GetObject("test-bucket", "key.bin")
+	if err != nil {
+		t.Fatalf("could not get object: %v", err)
+	}
+	stored, _ := io.ReadAll(reader)
+	reader.Close()
+	sum := md5.Sum(stored)
+	if parts[0].ETag != `"`+hex.EncodeToString(sum[:])+`"` || int64(len(stored)) != parts[0].Size {
+		t.Errorf("part ETag %s and size %d do not match the assembled content (%d bytes)", parts[0].ETag, parts[0].Size, len(stored))
+	}
+}